@@ -0,0 +1,135 @@
+package screw
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// File is the subset of *os.File that screw hands out. Files opened
+// through the OS backend are plain *os.File values.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.WriterAt
+	io.Seeker
+	io.Closer
+
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+	Readdir(n int) ([]os.FileInfo, error)
+	Readdirnames(n int) ([]string, error)
+	ReadDir(n int) ([]os.DirEntry, error)
+}
+
+// Backend provides the raw filesystem primitives screw builds its
+// case-sensible semantics on. A backend behaves like the filesystem it
+// models (CS or CPCI), with no case checks of its own.
+type Backend interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldpath, newpath string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Truncate(name string, size int64) error
+	Chmod(name string, mode os.FileMode) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+
+	// TrueBaseName returns the on-disk name of the last component of
+	// `name`, or "" if it doesn't exist.
+	TrueBaseName(name string) string
+
+	// FileTrueBaseName returns the on-disk name of an opened file,
+	// or "" if it can't be determined through its handle. Unlike
+	// TrueBaseName, it can't race with renames.
+	FileTrueBaseName(f File) string
+
+	// IsCaseInsensitive returns true for case-preserving,
+	// case-insensitive filesystems.
+	IsCaseInsensitive() bool
+}
+
+// OSBackend is the Backend for the real filesystem.
+type OSBackend struct{}
+
+var _ Backend = OSBackend{}
+
+func (OSBackend) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OSBackend) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSBackend) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSBackend) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (OSBackend) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSBackend) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSBackend) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (OSBackend) Rename(oldpath, newpath string) error {
+	return doRename(oldpath, newpath)
+}
+
+func (OSBackend) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (OSBackend) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (OSBackend) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+func (OSBackend) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (OSBackend) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (OSBackend) TrueBaseName(name string) string {
+	return osTrueBaseName(name)
+}
+
+func (OSBackend) FileTrueBaseName(f File) string {
+	osf, ok := f.(*os.File)
+	if !ok {
+		return ""
+	}
+	return fileTrueBaseName(osf)
+}
+
+func (OSBackend) IsCaseInsensitive() bool {
+	return osIsCaseInsensitiveFS()
+}
@@ -0,0 +1,106 @@
+package screw
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Canonicalize returns the absolute path of `name`, with every component
+// below the root (see WithRoot) in its on-disk casing. Components of the
+// root itself are kept as given, and without a root, every component is
+// canonicalized.
+//
+// Unlike TrueBaseName, which only gives the last component, it works by
+// listing directories, so symlinks are never resolved: on macOS, `/tmp`
+// stays `/tmp` rather than becoming `/private/tmp`.
+//
+// If `name` doesn't exist, it returns an error that satisfies os.IsNotExist.
+func (fs *FS) Canonicalize(name string) (canonical string, err error) {
+	rec, err := fs.begin("screw.Canonicalize", slog.String("path", name))
+	defer doneWith(rec, &canonical, &err)
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", wrap(err, "screw.Canonicalize", name)
+	}
+
+	root := fs.strictRoot(abs)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// outside of the root, only canonicalize the base name
+		root = filepath.Dir(abs)
+		rel = filepath.Base(abs)
+	}
+	if rel == "." {
+		if _, err := fs.backend.Lstat(abs); err != nil {
+			return "", lookupErr(err, name)
+		}
+		return abs, nil
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		candidate := filepath.Join(current, part)
+		if _, err := fs.backend.Lstat(candidate); err != nil {
+			return "", lookupErr(err, name)
+		}
+		current = filepath.Join(current, fs.trueName(current, part))
+	}
+	return current, nil
+}
+
+// lookupErr names a failed lookup after Canonicalize and the path it was
+// given, keeping the backend's underlying error, so that os.IsNotExist
+// still works.
+func lookupErr(err error, name string) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return wrap(err, "screw.Canonicalize", name)
+}
+
+// trueName returns the on-disk name of the existing entry `base` of
+// `dir`, as found in the directory's listing. If the listing doesn't
+// have it, for example because names are also normalized, it asks the
+// backend, and failing that, returns `base` unchanged.
+//
+// Listings aren't cached: the whole point is to find out what's on
+// disk right now.
+func (fs *FS) trueName(dir string, base string) string {
+	if names, err := fs.listNames(dir); err == nil {
+		var matches []string
+		for _, name := range names {
+			if name == base {
+				// on case-sensitive directories, that's the one
+				// we found, and on case-insensitive ones, it's
+				// the only one there is.
+				return base
+			}
+			if equalFold(name, base) {
+				matches = append(matches, name)
+			}
+		}
+		// case-insensitive directories can't have several variants
+		if len(matches) == 1 {
+			return matches[0]
+		}
+	}
+
+	if trueBase := fs.backend.TrueBaseName(filepath.Join(dir, base)); trueBase != "" {
+		return trueBase
+	}
+	return base
+}
+
+// Canonicalize returns the absolute path of `name` with every
+// component in its on-disk casing, see (*FS).Canonicalize
+func Canonicalize(name string) (string, error) {
+	return Default().Canonicalize(name)
+}
@@ -0,0 +1,82 @@
+package screw_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Canonicalize_Mem(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("Data", "Textures"), 0o755))
+	writeMem(mem, memPath("Data", "Textures", "Foo.png"), "foo")
+	fsys := screw.New(screw.WithBackend(mem))
+
+	canonical, err := fsys.Canonicalize(memPath("data", "TEXTURES", "foo.PNG"))
+	assert.NoError(err)
+	assert.EqualValues(memPath("Data", "Textures", "Foo.png"), canonical)
+
+	canonical, err = fsys.Canonicalize(memPath("Data", "Textures", "Foo.png"))
+	assert.NoError(err)
+	assert.EqualValues(memPath("Data", "Textures", "Foo.png"), canonical)
+
+	// renames are seen, whether they're made through the FS or not
+	must(fsys.Rename(memPath("Data", "Textures"), memPath("Data", "textures")))
+	canonical, err = fsys.Canonicalize(memPath("data", "TEXTURES", "foo.PNG"))
+	assert.NoError(err)
+	assert.EqualValues(memPath("Data", "textures", "Foo.png"), canonical)
+
+	must(mem.Rename(memPath("Data", "textures", "Foo.png"), memPath("Data", "textures", "FOO.png")))
+	canonical, err = fsys.Canonicalize(memPath("Data", "textures", "Foo.png"))
+	assert.NoError(err)
+	assert.EqualValues(memPath("Data", "textures", "FOO.png"), canonical)
+
+	_, err = fsys.Canonicalize(memPath("data", "sounds", "foo.ogg"))
+	assert.True(os.IsNotExist(err))
+	var pathErr *os.PathError
+	if assert.True(errors.As(err, &pathErr)) {
+		assert.EqualValues("screw.Canonicalize", pathErr.Op)
+		assert.EqualValues(memPath("data", "sounds", "foo.ogg"), pathErr.Path)
+	}
+
+	// components of the root are kept as given
+	rooted := fsys.With(screw.WithRoot(memPath("DATA")))
+	canonical, err = rooted.Canonicalize(memPath("DATA", "TEXTURES", "foo.PNG"))
+	assert.NoError(err)
+	assert.EqualValues(memPath("DATA", "textures", "FOO.png"), canonical)
+}
+
+func Test_Canonicalize_OS(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := t.TempDir()
+	must(os.MkdirAll(filepath.Join(tmpDir, "Data"), 0o755))
+	must(os.WriteFile(filepath.Join(tmpDir, "Data", "Foo.png"), []byte("foo"), 0o644))
+
+	canonical, err := screw.Canonicalize(filepath.Join(tmpDir, "Data", "Foo.png"))
+	assert.NoError(err)
+	assert.EqualValues(filepath.Join(tmpDir, "Data", "Foo.png"), canonical)
+
+	insensitive, err := screw.IsCaseInsensitiveDir(tmpDir)
+	must(err)
+	_, err = screw.Canonicalize(filepath.Join(tmpDir, "DATA", "foo.PNG"))
+	if insensitive {
+		assert.NoError(err)
+	} else {
+		assert.True(os.IsNotExist(err))
+	}
+
+	// symlinks aren't resolved
+	if err := os.Symlink(filepath.Join(tmpDir, "Data"), filepath.Join(tmpDir, "Link")); err != nil {
+		t.Skipf("can't create symlinks: %+v", err)
+	}
+	canonical, err = screw.Canonicalize(filepath.Join(tmpDir, "Link", "Foo.png"))
+	assert.NoError(err)
+	assert.EqualValues(filepath.Join(tmpDir, "Link", "Foo.png"), canonical)
+}
@@ -0,0 +1,160 @@
+package screw
+
+import (
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CaseCollision is a group of paths that only differ by case, and that
+// would end up as a single entry on a case-insensitive filesystem: one
+// file would overwrite the others, or directories would be merged.
+type CaseCollision struct {
+	// Paths are relative to the root that was searched, use forward
+	// slashes, and are sorted.
+	Paths []string
+}
+
+// FindCaseCollisions walks the tree at `root` and returns every group
+// of entries that would collide on a case-insensitive filesystem.
+//
+// Directories that collide are reported, and their contents are then
+// looked at as if they had been merged, so `Data/a.png` and `data/A.png`
+// are reported too. Symlinks are not followed. Names that only differ
+// by Unicode normalization collide too, unless the FS uses
+// NormalizationDistinct, see WithNormalization.
+//
+// Names collide if they would on any case-insensitive filesystem (see
+// FoldFlavor), not just on the one screw runs on: "straße" and "STRASSE"
+// collide on APFS, so they're reported on Windows too.
+func (fs *FS) FindCaseCollisions(root string) (collisions []CaseCollision, err error) {
+	rec, err := fs.begin("screw.FindCaseCollisions", slog.String("path", root))
+	defer doneWith(rec, &collisions, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := newCollisionNode("")
+	if err := fs.collisionWalk(root, tree); err != nil {
+		return nil, err
+	}
+	return findCollisions([]*collisionNode{tree}, fs.portableKey), nil
+}
+
+func (fs *FS) collisionWalk(dir string, node *collisionNode) error {
+	f, err := fs.backend.OpenFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		child := node.add(name)
+		stats, err := fs.backend.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if stats.IsDir() {
+			if err := fs.collisionWalk(filepath.Join(dir, name), child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindPathCaseCollisions is FindCaseCollisions for a list of relative
+// paths, for example the contents of an archive or a manifest, rather
+// than a tree on disk. Parents are implied: `Data/a.png` and `data/b.png`
+// collide on `Data` and `data`.
+func (fs *FS) FindPathCaseCollisions(paths []string) []CaseCollision {
+	tree := newCollisionNode("")
+	for _, p := range paths {
+		p = path.Clean(filepath.ToSlash(p))
+		if p == "." || p == "/" {
+			continue
+		}
+		node := tree
+		for _, part := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+			node = node.add(part)
+		}
+	}
+	return findCollisions([]*collisionNode{tree}, fs.portableKey)
+}
+
+// collisionNode is an entry of the tree being searched, by exact name
+type collisionNode struct {
+	path     string
+	children map[string]*collisionNode
+}
+
+func newCollisionNode(path string) *collisionNode {
+	return &collisionNode{
+		path:     path,
+		children: make(map[string]*collisionNode),
+	}
+}
+
+func (n *collisionNode) add(name string) *collisionNode {
+	if child, ok := n.children[name]; ok {
+		return child
+	}
+	child := newCollisionNode(path.Join(n.path, name))
+	n.children[name] = child
+	return child
+}
+
+// findCollisions looks for collisions among the children of `dirs`,
+// which all end up as the same directory on a case-insensitive
+// filesystem. Names collide if they have the same key.
+func findCollisions(dirs []*collisionNode, nameKey func(string) string) []CaseCollision {
+	groups := make(map[string][]*collisionNode)
+	for _, dir := range dirs {
+		for name, child := range dir.children {
+			key := nameKey(name)
+			groups[key] = append(groups[key], child)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var collisions []CaseCollision
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) > 1 {
+			paths := make([]string, len(group))
+			for i, node := range group {
+				paths[i] = node.path
+			}
+			sort.Strings(paths)
+			collisions = append(collisions, CaseCollision{Paths: paths})
+		}
+		collisions = append(collisions, findCollisions(group, nameKey)...)
+	}
+	return collisions
+}
+
+// FindCaseCollisions walks the tree at `root` and returns every group
+// of entries that would collide on a case-insensitive filesystem, see
+// (*FS).FindCaseCollisions
+func FindCaseCollisions(root string) ([]CaseCollision, error) {
+	return Default().FindCaseCollisions(root)
+}
+
+// FindPathCaseCollisions returns every group of paths in `paths` that
+// would collide on a case-insensitive filesystem, see
+// (*FS).FindPathCaseCollisions
+func FindPathCaseCollisions(paths []string) []CaseCollision {
+	return Default().FindPathCaseCollisions(paths)
+}
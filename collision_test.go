@@ -0,0 +1,83 @@
+package screw_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FindPathCaseCollisions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(screw.FindPathCaseCollisions([]string{
+		"Data/apricot.png",
+		"Data/banana.png",
+		"Data/",
+		"readme.txt",
+	}))
+
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"Apricot.png", "apricot.png"}},
+	}, screw.FindPathCaseCollisions([]string{"apricot.png", "Apricot.png", "apricot.png"}))
+
+	// parents collide, and their contents are merged
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"DATA", "Data", "data"}},
+		{Paths: []string{"DATA/textures", "data/Textures"}},
+		{Paths: []string{"DATA/textures/Foo.png", "data/Textures/foo.png"}},
+	}, screw.FindPathCaseCollisions([]string{
+		"Data/readme.txt",
+		"data/Textures/foo.png",
+		"DATA/textures/Foo.png",
+		"data/Textures/bar.png",
+	}))
+
+	// names collide if they would on any case-insensitive filesystem,
+	// whatever the platform: APFS folds "straße" like "STRASSE", HFS+
+	// ignores zero-width non-joiners, NTFS does neither
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"ab", "a\u200cb"}},
+		{Paths: []string{"STRASSE", "stra\u00dfe"}},
+	}, screw.FindPathCaseCollisions([]string{"stra\u00dfe", "STRASSE", "a\u200cb", "ab"}))
+	assert.Len(screw.FindPathCaseCollisions([]string{"\u212a", "k"}), 1, "kelvin sign")
+
+	// OS separators are accepted
+	assert.Len(screw.FindPathCaseCollisions([]string{
+		filepath.Join("a", "b"),
+		"a/B",
+	}), 1)
+}
+
+func Test_FindCaseCollisions(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := caseSensitiveTempDir(t)
+	must(os.MkdirAll(filepath.Join(tmpDir, "Data", "Sounds"), 0o755))
+	must(os.MkdirAll(filepath.Join(tmpDir, "data"), 0o755))
+	must(os.WriteFile(filepath.Join(tmpDir, "Apricot.png"), nil, 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "apricot.png"), nil, 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "banana.png"), nil, 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "data", "sounds"), nil, 0o644))
+
+	collisions, err := screw.FindCaseCollisions(tmpDir)
+	assert.NoError(err)
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"Apricot.png", "apricot.png"}},
+		{Paths: []string{"Data", "data"}},
+		{Paths: []string{"Data/Sounds", "data/sounds"}},
+	}, collisions)
+
+	// no collisions can exist on a case-insensitive filesystem
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("Data"), 0o755))
+	writeMem(mem, memPath("Data", "apricot.png"), "apricot")
+	collisions, err = screw.New(screw.WithBackend(mem)).FindCaseCollisions(memPath())
+	assert.NoError(err)
+	assert.Empty(collisions)
+
+	_, err = screw.FindCaseCollisions(filepath.Join(tmpDir, "nope"))
+	assert.True(os.IsNotExist(err))
+}
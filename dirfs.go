@@ -0,0 +1,124 @@
+package screw
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+)
+
+// DirFS returns an fs.FS for the tree rooted at `dir`, with the same
+// case-sensible semantics as the FS it's made from: opening, statting
+// or listing a path with the wrong case fails with fs.ErrNotExist.
+// Since `dir` is a natural root, every component below it is checked,
+// not just the last one, as if in CaseStrict mode with WithRoot(dir).
+// Lenient and passthrough FSes keep their mode, with `dir` as the root.
+//
+// The result also implements fs.StatFS, fs.ReadDirFS, fs.ReadFileFS
+// and fs.GlobFS. Like os.DirFS, errors name paths relative to `dir`.
+func (fs *FS) DirFS(dir string) fs.FS {
+	opts := []Option{WithRoot(dir)}
+	if fs.caseMode == CaseSensible {
+		opts = append(opts, WithCaseMode(CaseStrict))
+	}
+	return &dirFS{fsys: fs.With(opts...), dir: dir}
+}
+
+type dirFS struct {
+	fsys *FS
+	dir  string
+}
+
+var (
+	_ fs.StatFS     = (*dirFS)(nil)
+	_ fs.ReadDirFS  = (*dirFS)(nil)
+	_ fs.ReadFileFS = (*dirFS)(nil)
+	_ fs.GlobFS     = (*dirFS)(nil)
+)
+
+// join validates `name` and returns the path it refers to
+func (d *dirFS) join(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	// unlike fs.ValidPath, this rejects names that would mean something
+	// else on this OS, like `..\secret` or "C:" on Windows
+	local, err := filepath.Localize(name)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, local), nil
+}
+
+// relErr makes errors name `name` rather than the full path
+func relErr(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+func (d *dirFS) Open(name string) (fs.File, error) {
+	full, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.fsys.Open(full)
+	if err != nil {
+		return nil, relErr(err, name)
+	}
+	return f, nil
+}
+
+func (d *dirFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := d.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := d.fsys.Stat(full)
+	if err != nil {
+		return nil, relErr(err, name)
+	}
+	return stats, nil
+}
+
+func (d *dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := d.fsys.ReadDir(full)
+	if err != nil {
+		return nil, relErr(err, name)
+	}
+	entries := make([]fs.DirEntry, len(list))
+	for i, info := range list {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+func (d *dirFS) ReadFile(name string) ([]byte, error) {
+	full, err := d.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.fsys.ReadFile(full)
+	if err != nil {
+		return nil, relErr(err, name)
+	}
+	return data, nil
+}
+
+// Glob matches names as listed by ReadDir, so patterns are
+// case-sensitive, even on case-insensitive filesystems.
+func (d *dirFS) Glob(pattern string) ([]string, error) {
+	// hide the Glob method, so that fs.Glob uses ReadDir
+	// instead of calling back into us.
+	return fs.Glob(struct{ fs.ReadDirFS }{d}, pattern)
+}
+
+// DirFS returns an fs.FS for the tree rooted at `dir`, see (*FS).DirFS
+func DirFS(dir string) fs.FS {
+	return Default().DirFS(dir)
+}
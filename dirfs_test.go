@@ -0,0 +1,104 @@
+package screw_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DirFS_Mem(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("root", "Textures"), 0o755))
+	writeMem(mem, memPath("root", "Readme.txt"), "readme")
+	writeMem(mem, memPath("root", "Textures", "Foo.png"), "foo")
+	fsys := screw.New(screw.WithBackend(mem)).DirFS(memPath("root"))
+
+	if err := fstest.TestFS(fsys, "Readme.txt", "Textures/Foo.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// wrong case is a miss, even though the backend is case-insensitive
+	_, err := fsys.Open("readme.txt")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+	_, err = fs.Stat(fsys, "Textures/FOO.png")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+	var pe *fs.PathError
+	if assert.True(errors.As(err, &pe)) {
+		assert.EqualValues("Textures/FOO.png", pe.Path)
+	}
+	_, err = fs.ReadFile(fsys, "Textures/foo.PNG")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+	_, err = fs.ReadDir(fsys, "textures")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+
+	// and so is a parent with the wrong case
+	_, err = fs.ReadFile(fsys, "textures/Foo.png")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+	_, err = fs.Stat(fsys, "TEXTURES/Foo.png")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+	if assert.True(errors.As(err, &pe)) {
+		assert.EqualValues("TEXTURES/Foo.png", pe.Path)
+	}
+	_, err = fsys.Open("textures/Foo.png")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+
+	matches, err := fs.Glob(fsys, "*/*.png")
+	assert.NoError(err)
+	assert.EqualValues([]string{"Textures/Foo.png"}, matches)
+
+	matches, err = fs.Glob(fsys, "textures/foo.png")
+	assert.NoError(err)
+	assert.Empty(matches)
+
+	_, err = fsys.Open("../root/Readme.txt")
+	assert.True(errors.Is(err, fs.ErrInvalid))
+}
+
+func Test_DirFS_Invalid(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("root"), 0o755))
+	writeMem(mem, memPath("secret"), "secret")
+	fsys := screw.New(screw.WithBackend(mem)).DirFS(memPath("root"))
+
+	names := []string{"../secret", "/secret", "./secret", ""}
+	if runtime.GOOS == "windows" {
+		// valid for io/fs, but separators or volume names on Windows
+		names = append(names, `..\secret`, `..\..\secret`, `C:\secret`, "C:")
+	} else {
+		// elsewhere, a backslash is part of the name, which doesn't exist
+		_, err := fsys.Open(`..\secret`)
+		assert.True(errors.Is(err, fs.ErrNotExist), "got %+v", err)
+	}
+	for _, name := range names {
+		_, err := fsys.Open(name)
+		assert.True(errors.Is(err, fs.ErrInvalid), "%q: got %+v", name, err)
+		_, err = fs.ReadFile(fsys, name)
+		assert.True(errors.Is(err, fs.ErrInvalid), "%q: got %+v", name, err)
+	}
+}
+
+func Test_DirFS_OS(t *testing.T) {
+	tmpDir := t.TempDir()
+	must(os.MkdirAll(filepath.Join(tmpDir, "Textures"), 0o755))
+	must(os.WriteFile(filepath.Join(tmpDir, "Readme.txt"), []byte("readme"), 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "Textures", "Foo.png"), []byte("foo"), 0o644))
+
+	fsys := screw.DirFS(tmpDir)
+	if err := fstest.TestFS(fsys, "Readme.txt", "Textures/Foo.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := fsys.Open("Textures/foo.png")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
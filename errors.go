@@ -0,0 +1,130 @@
+package screw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrCaseConflict  = errors.New("a file with a different case already exists on disk")
+	ErrAmbiguousCase = errors.New("several case variants exist on disk")
+	ErrNotPortable   = errors.New("name can't be used on every platform")
+	// ErrNotOSFile is returned by package-level functions that return an
+	// *os.File, like Open, when the default FS's backend opened something
+	// else, see SetDefault.
+	ErrNotOSFile = errors.New("the default FS's backend didn't open an *os.File")
+)
+
+// CaseConflictError is returned when an operation can't proceed
+// because a case variant of the requested path exists on disk,
+// for example when creating "apricot" while "APRICOT" exists.
+//
+// errors.Is(err, ErrCaseConflict), errors.Is(err, fs.ErrExist) and
+// IsExist(err) all hold for a *CaseConflictError.
+type CaseConflictError struct {
+	// Op is the operation that failed, like "screw.Create"
+	Op string
+	// Path is the path that was asked for
+	Path string
+	// Actual is the path as it exists on disk. In strict mode,
+	// it may be one of the parents of the requested path.
+	Actual string
+}
+
+func (e *CaseConflictError) Error() string {
+	return fmt.Sprintf("%s %s: %s already exists on disk with a different case", e.Op, e.Path, filepath.Base(e.Actual))
+}
+
+func (e *CaseConflictError) Unwrap() error {
+	return ErrCaseConflict
+}
+
+func (e *CaseConflictError) Is(target error) bool {
+	return target == fs.ErrExist
+}
+
+// IsCaseConflict returns true if `err` is (or wraps) a case conflict,
+// ie. the operation failed because a case variant of the requested
+// path already exists.
+func IsCaseConflict(err error) bool {
+	return errors.Is(err, ErrCaseConflict)
+}
+
+// IsExist is like os.IsExist, but also holds for case conflicts.
+// os.IsExist only looks inside the error types of the os package, and
+// only for syscall errors, so it can't see that a *CaseConflictError
+// means something already exists.
+func IsExist(err error) bool {
+	return errors.Is(err, fs.ErrExist)
+}
+
+// AmbiguousCaseError is returned in CaseLenient mode when a path
+// component doesn't exist as given, and several of its case variants
+// do, for example when opening "apricot" while both "APRICOT" and
+// "Apricot" exist.
+//
+// errors.Is(err, ErrAmbiguousCase) holds for an *AmbiguousCaseError.
+type AmbiguousCaseError struct {
+	// Op is the operation that failed, like "screw.Open"
+	Op string
+	// Path is the path of the ambiguous component, as requested
+	Path string
+	// Candidates are the paths of the case variants found on disk
+	Candidates []string
+}
+
+func (e *AmbiguousCaseError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, candidate := range e.Candidates {
+		names[i] = filepath.Base(candidate)
+	}
+	return fmt.Sprintf("%s %s: ambiguous, several case variants exist on disk: %s", e.Op, e.Path, strings.Join(names, ", "))
+}
+
+func (e *AmbiguousCaseError) Unwrap() error {
+	return ErrAmbiguousCase
+}
+
+// IsAmbiguousCase returns true if `err` is (or wraps) an ambiguous
+// case error, see AmbiguousCaseError.
+func IsAmbiguousCase(err error) bool {
+	return errors.Is(err, ErrAmbiguousCase)
+}
+
+// PortabilityError is returned by ValidatePortableName and
+// ValidatePortablePath, and by operations of an FS created with
+// WithPortableNames, when a name can't be used on Windows or macOS.
+//
+// errors.Is(err, ErrNotPortable) and errors.Is(err, fs.ErrInvalid)
+// both hold for a *PortabilityError.
+type PortabilityError struct {
+	// Path is the name or path that was validated
+	Path string
+	// Problems lists every reason it isn't portable, in order
+	Problems []PortabilityProblem
+}
+
+func (e *PortabilityError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = problem.String()
+	}
+	return fmt.Sprintf("%s: not portable: %s", e.Path, strings.Join(problems, ", "))
+}
+
+func (e *PortabilityError) Unwrap() error {
+	return ErrNotPortable
+}
+
+func (e *PortabilityError) Is(target error) bool {
+	return target == fs.ErrInvalid
+}
+
+// IsNotPortable returns true if `err` is (or wraps) a portability
+// error, see PortabilityError.
+func IsNotPortable(err error) bool {
+	return errors.Is(err, ErrNotPortable)
+}
@@ -0,0 +1,78 @@
+package screw_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CaseConflictError(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("APRICOT"), "apricot")
+
+	fsys := screw.New(screw.WithBackend(mem))
+	_, err := fsys.Create(memPath("apricot"))
+
+	var cce *screw.CaseConflictError
+	if assert.True(errors.As(err, &cce)) {
+		assert.EqualValues("screw.Create", cce.Op)
+		assert.EqualValues(memPath("apricot"), cce.Path)
+		assert.EqualValues(memPath("APRICOT"), cce.Actual)
+		assert.Contains(cce.Error(), "APRICOT")
+	}
+	assert.True(errors.Is(err, screw.ErrCaseConflict))
+	assert.True(errors.Is(err, fs.ErrExist))
+	assert.True(screw.IsExist(err))
+	assert.True(screw.IsCaseConflict(err))
+
+	// IsExist works for regular errors too
+	err = fsys.Mkdir(memPath("APRICOT"), 0o755)
+	assert.True(os.IsExist(err))
+	assert.True(screw.IsExist(err))
+	assert.False(screw.IsCaseConflict(err))
+
+	// non-conflicts aren't case conflicts
+	_, err = fsys.Open(memPath("apricot"))
+	assert.False(screw.IsCaseConflict(err))
+	assert.False(errors.As(err, &cce))
+}
+
+func Test_CaseConflictError_Strict(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("FOO"), 0o755))
+
+	fsys := screw.New(screw.WithBackend(mem), screw.WithCaseMode(screw.CaseStrict), screw.WithRoot(memPath()))
+	err := fsys.Mkdir(memPath("foo", "bar"), 0o755)
+
+	var cce *screw.CaseConflictError
+	if assert.True(errors.As(err, &cce)) {
+		assert.EqualValues("screw.Mkdir", cce.Op)
+		assert.EqualValues(memPath("foo"), cce.Path)
+		assert.EqualValues(memPath("FOO"), cce.Actual)
+	}
+}
+
+func Test_CaseConflictError_Directory(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("APRICOT"), 0o755))
+
+	// opening a directory for writing fails with EISDIR, not EEXIST,
+	// but that's still a case conflict
+	fsys := screw.New(screw.WithBackend(mem))
+	_, err := fsys.Create(memPath("apricot"))
+	assert.True(screw.IsCaseConflict(err), "got %+v", err)
+
+	_, err = fsys.OpenFile(memPath("apricot"), os.O_WRONLY, 0)
+	assert.True(os.IsNotExist(err), "got %+v", err)
+}
@@ -0,0 +1,152 @@
+package screw
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldFlavor selects the rules a case-insensitive filesystem uses to
+// decide whether two names are the same. They agree on ASCII, but not
+// beyond: for example, APFS considers "straße" and "STRASSE" the same
+// name, NTFS and HFS+ don't.
+type FoldFlavor int
+
+const (
+	// FoldNTFS maps each UTF-16 code unit through a simple uppercase
+	// table, with no normalization: characters outside of the Basic
+	// Multilingual Plane are compared as-is, and so are characters whose
+	// uppercase form is ASCII, like the Turkish dotless i.
+	FoldNTFS FoldFlavor = iota
+	// FoldAPFS applies full Unicode case folding and canonical
+	// decomposition (NFD). Linux casefold directories (ext4, f2fs)
+	// follow the same rules.
+	FoldAPFS
+	// FoldHFSPlus decomposes names, then maps them through a simple
+	// lowercase table, ignoring a few invisible formatting characters.
+	// It's an approximation: HFS+ uses a table frozen in the Unicode 2.x
+	// days, this uses the current one, so characters added or changed
+	// since (like U+1E9E, capital sharp s) may not fold like they do on
+	// a real HFS+ volume.
+	FoldHFSPlus
+)
+
+func (f FoldFlavor) String() string {
+	switch f {
+	case FoldNTFS:
+		return "ntfs"
+	case FoldAPFS:
+		return "apfs"
+	case FoldHFSPlus:
+		return "hfs+"
+	default:
+		return "unknown"
+	}
+}
+
+// PlatformFoldFlavor returns the flavor screw uses to compare names on
+// the current platform.
+func PlatformFoldFlavor() FoldFlavor {
+	return platformFoldFlavor
+}
+
+// FoldName returns the form of `name` that all of its case variants
+// share under `flavor`. Only compare folded names to each other, the
+// form itself is an implementation detail.
+func FoldName(name string, flavor FoldFlavor) string {
+	switch {
+	case flavor == FoldNTFS || isASCII(name):
+		return foldCase(name, flavor)
+	case flavor == FoldHFSPlus:
+		return foldCase(norm.NFD.String(name), flavor)
+	default:
+		return norm.NFD.String(foldCase(norm.NFD.String(name), flavor))
+	}
+}
+
+// foldCase is FoldName without normalization, names that only
+// differ by it fold differently.
+func foldCase(name string, flavor FoldFlavor) string {
+	if isASCII(name) {
+		if flavor == FoldNTFS {
+			return strings.ToUpper(name)
+		}
+		return strings.ToLower(name)
+	}
+
+	switch flavor {
+	case FoldNTFS:
+		return strings.Map(ntfsUpcase, name)
+	case FoldHFSPlus:
+		return strings.Map(hfsLowercase, name)
+	default:
+		return cases.Fold().String(name)
+	}
+}
+
+// EqualFold returns true if `a` and `b` name the same entry on
+// a case-insensitive filesystem of the given flavor.
+func EqualFold(a, b string, flavor FoldFlavor) bool {
+	if a == b {
+		return true
+	}
+	return FoldName(a, flavor) == FoldName(b, flavor)
+}
+
+// foldName is FoldName with the platform flavor
+func foldName(name string) string {
+	return FoldName(name, platformFoldFlavor)
+}
+
+// equalFold is EqualFold with the platform flavor
+func equalFold(a, b string) bool {
+	return EqualFold(a, b, platformFoldFlavor)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func ntfsUpcase(r rune) rune {
+	if r > 0xFFFF {
+		// surrogate pairs are upcased one code unit at a time,
+		// which leaves them unchanged
+		return r
+	}
+	upper := unicode.ToUpper(r)
+	if upper > 0xFFFF || (r >= utf8.RuneSelf && upper < utf8.RuneSelf) {
+		// the table never maps to ASCII from outside of it,
+		// so that "ı" and "ſ" aren't the same as "I" and "S".
+		return r
+	}
+	return upper
+}
+
+// hfsLowercase approximates the HFS+ lowercase table with the
+// current Unicode one, see FoldHFSPlus
+func hfsLowercase(r rune) rune {
+	switch {
+	case hfsIgnorable(r):
+		return -1
+	case r > 0xFFFF:
+		return r
+	}
+	return unicode.ToLower(r)
+}
+
+// hfsIgnorable returns true for the invisible formatting characters
+// HFS+ ignores when comparing names
+func hfsIgnorable(r rune) bool {
+	return (r >= 0x200C && r <= 0x200F) ||
+		(r >= 0x202A && r <= 0x202E) ||
+		(r >= 0x206A && r <= 0x206F) ||
+		r == 0xFEFF
+}
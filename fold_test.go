@@ -0,0 +1,84 @@
+package screw_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EqualFold(t *testing.T) {
+	testCases := []struct {
+		name  string
+		a, b  string
+		ntfs  bool
+		apfs  bool
+		hfsPl bool
+	}{
+		{"ascii", "Apricot.PNG", "apricot.png", true, true, true},
+		{"different", "apricot", "banana", false, false, false},
+		{"accented", "\u00c9clair", "\u00e9clair", true, true, true},
+		{"sharp s", "stra\u00dfe", "STRASSE", false, true, false},
+		{"dotless i", "\u0131", "I", false, false, false},
+		{"dotted capital i", "\u0130", "i", false, false, false},
+		{"long s", "\u017f", "s", false, true, false},
+		{"final sigma", "\u03c2", "\u03a3", true, true, false},
+		{"sigma", "\u03c3", "\u03a3", true, true, true},
+		{"kelvin sign", "\u212a", "k", false, true, true},
+		{"decomposed", "\u00e9", "e\u0301", false, true, true},
+		{"decomposed and cased", "\u00c9", "e\u0301", false, true, true},
+		{"zero-width non-joiner", "a\u200cb", "ab", false, false, true},
+		{"deseret", "\U00010400", "\U00010428", false, true, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.EqualValues(tc.ntfs, screw.EqualFold(tc.a, tc.b, screw.FoldNTFS), "ntfs")
+			assert.EqualValues(tc.apfs, screw.EqualFold(tc.a, tc.b, screw.FoldAPFS), "apfs")
+			assert.EqualValues(tc.hfsPl, screw.EqualFold(tc.a, tc.b, screw.FoldHFSPlus), "hfs+")
+		})
+	}
+
+	// capital sharp s came after HFS+ froze its table, which screw only
+	// approximates, so there's nothing to check it against
+	assert.False(t, screw.EqualFold("\u00df", "\u1e9e", screw.FoldNTFS))
+	assert.True(t, screw.EqualFold("\u00df", "\u1e9e", screw.FoldAPFS))
+}
+
+func Test_FoldName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, flavor := range []screw.FoldFlavor{screw.FoldNTFS, screw.FoldAPFS, screw.FoldHFSPlus} {
+		assert.EqualValues(screw.FoldName("Apricot", flavor), screw.FoldName("APRICOT", flavor), flavor.String())
+		assert.NotEqualValues(screw.FoldName("Apricot", flavor), screw.FoldName("Banana", flavor), flavor.String())
+	}
+
+	if runtime.GOOS == "windows" {
+		assert.EqualValues(screw.FoldNTFS, screw.PlatformFoldFlavor())
+	} else {
+		assert.EqualValues(screw.FoldAPFS, screw.PlatformFoldFlavor())
+	}
+}
+
+func Test_Fold_MemBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("\u00e9clair"), "eclair")
+	fsys := screw.New(screw.WithBackend(mem))
+
+	// the backend folds names like the platform does
+	assert.EqualValues("\u00e9clair", mem.TrueBaseName(memPath("\u00c9clair")))
+	if screw.PlatformFoldFlavor() == screw.FoldAPFS {
+		assert.EqualValues("\u00e9clair", mem.TrueBaseName(memPath("e\u0301clair")))
+	}
+
+	// and screw compares names the same way
+	_, err := fsys.Stat(memPath("\u00c9clair"))
+	assert.Error(err)
+	must(fsys.Rename(memPath("\u00e9clair"), memPath("\u00c9clair")))
+	assert.EqualValues("\u00c9clair", mem.TrueBaseName(memPath("\u00e9clair")))
+}
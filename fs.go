@@ -0,0 +1,868 @@
+package screw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// FS provides case-sensible semantics on top of a Backend.
+// The package-level functions use a default FS, backed by the
+// real filesystem, see Default.
+type FS struct {
+	backend  Backend
+	retry    RetryPolicy
+	logger   *slog.Logger
+	caseMode CaseMode
+	root     string
+
+	// how names that only differ by normalization are treated
+	normalization NormalizationPolicy
+
+	// whether names that aren't portable can be created
+	portableNames bool
+
+	// whether logged records include a stack trace
+	stackTraces bool
+
+	hooks []Hook
+
+	violations *violationHandlers
+
+	// parent directories already verified in strict mode
+	verified *caseCache
+
+	// directory listings used to resolve case in lenient mode
+	listings *listingCache
+
+	// case-sensitivity probe results, see IsCaseInsensitiveDir
+	probes *probeCache
+
+	// busy files ReplaceFile couldn't remove yet
+	replaced *replacedList
+}
+
+// CaseMode controls which case checks an FS performs
+type CaseMode int
+
+const (
+	// CaseSensible is the default mode, see the README for
+	// a description of case-sensible semantics.
+	CaseSensible CaseMode = iota
+	// CasePassthrough disables case checks altogether: operations
+	// have the semantics of the underlying backend.
+	CasePassthrough
+	// CaseStrict is like CaseSensible, but checks every path component
+	// below the root (see WithRoot), instead of only the last one.
+	CaseStrict
+	// CaseLenient is the opposite of CaseStrict: path components below
+	// the root (see WithRoot) that don't exist as given are resolved to
+	// their on-disk case variant, by listing directories, so that paths
+	// written on Windows work on case-sensitive filesystems. If several
+	// variants exist, operations fail with an *AmbiguousCaseError.
+	CaseLenient
+)
+
+func (cm CaseMode) String() string {
+	switch cm {
+	case CaseSensible:
+		return "sensible"
+	case CasePassthrough:
+		return "passthrough"
+	case CaseStrict:
+		return "strict"
+	case CaseLenient:
+		return "lenient"
+	default:
+		return fmt.Sprintf("CaseMode(%d)", int(cm))
+	}
+}
+
+// Option configures an FS, see New
+type Option func(fs *FS)
+
+// WithBackend makes an FS operate on the given backend instead
+// of the real filesystem.
+func WithBackend(backend Backend) Option {
+	return func(fs *FS) {
+		fs.backend = backend
+		// what we know about another backend doesn't apply
+		fs.verified = newCaseCache()
+		fs.listings = newListingCache()
+		fs.probes = newProbeCache()
+	}
+}
+
+// WithRetryPolicy sets how operations that may fail spuriously
+// (because of antivirus software, for example) are retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(fs *FS) {
+		fs.retry = policy
+	}
+}
+
+// WithLogger sends a record for every operation to the given logger,
+// at debug level (details are logged at LevelTrace). Without a logger,
+// records are discarded, unless DEBUG is set. To log package-level
+// functions, see SetDefault.
+func WithLogger(logger *slog.Logger) Option {
+	return func(fs *FS) {
+		fs.logger = logger
+	}
+}
+
+// WithStackTraces adds a "stack" attribute to every logged record,
+// see WithLogger
+func WithStackTraces(enabled bool) Option {
+	return func(fs *FS) {
+		fs.stackTraces = enabled
+	}
+}
+
+// WithCaseMode sets which case checks are performed, see CaseMode
+func WithCaseMode(mode CaseMode) Option {
+	return func(fs *FS) {
+		fs.caseMode = mode
+	}
+}
+
+func New(opts ...Option) *FS {
+	fs := &FS{
+		backend:    OSBackend{},
+		retry:      DefaultRetryPolicy(),
+		verified:   newCaseCache(),
+		listings:   newListingCache(),
+		probes:     newProbeCache(),
+		replaced:   &replacedList{},
+		violations: &violationHandlers{},
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	return fs
+}
+
+// With returns a copy of fs with the given options applied, for
+// example to use another retry policy for a single call:
+//
+//	fs.With(screw.WithRetryPolicy(policy)).Rename(oldpath, newpath)
+//
+// The copy shares fs's caches, unless given another backend.
+func (fs *FS) With(opts ...Option) *FS {
+	clone := *fs
+	for _, opt := range opts {
+		opt(&clone)
+	}
+	return &clone
+}
+
+var defaultFS atomic.Pointer[FS]
+
+func init() {
+	defaultFS.Store(New())
+}
+
+// Default returns the FS used by package-level functions
+func Default() *FS {
+	return defaultFS.Load()
+}
+
+// SetDefault makes package-level functions use `fs`, which must not be
+// nil. That's how to attach a hook or a logger to every operation of
+// a program, including those made through screw.Open, screw.Rename, etc.:
+//
+//	screw.SetDefault(screw.Default().With(screw.WithHook(hook)))
+//
+// It's safe to call while package-level functions are in use: each
+// call uses either the previous FS or the new one.
+//
+// Every package-level function goes through `fs`, including TrueBaseName
+// and IsCaseInsensitiveFS. Open, Create and OpenFile return an *os.File,
+// so with a backend that opens other kinds of files, like MemBackend,
+// they fail with ErrNotOSFile: use the FS's own methods instead.
+func SetDefault(fs *FS) {
+	defaultFS.Store(fs)
+}
+
+// Backend returns the backend this FS operates on
+func (fs *FS) Backend() Backend {
+	return fs.backend
+}
+
+// Returns true if `name` exists on disk but
+// with a different case.
+// Returns false in any other case.
+func (fs *FS) IsWrongCase(name string) bool {
+	return fs.trueBaseIfWrong(name) != ""
+}
+
+// trueBaseIfWrong returns the on-disk name of the last component
+// of `name` if it differs in case, and "" otherwise.
+func (fs *FS) trueBaseIfWrong(name string) string {
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return ""
+	}
+	trueBase := fs.backend.TrueBaseName(name)
+	if trueBase != "" && !fs.sameName(trueBase, filepath.Base(name)) {
+		return trueBase
+	}
+	return ""
+}
+
+// checkCase returns an error (see caseError) if `name` exists on disk
+// with a different case, or in strict mode, if any of its parents do.
+// Returns nil otherwise.
+func (fs *FS) checkCase(op string, name string, err error) error {
+	var wrong, actual string
+	switch fs.caseMode {
+	case CasePassthrough, CaseLenient:
+		return nil
+	case CaseStrict:
+		wrong, actual = fs.wrongCaseComponent(name)
+	default:
+		// names can't have the wrong case in case-sensitive directories
+		if !fs.caseInsensitiveAt(name, err == ErrCaseConflict) {
+			return nil
+		}
+		if trueBase := fs.trueBaseIfWrong(name); trueBase != "" {
+			wrong, actual = name, filepath.Join(filepath.Dir(name), trueBase)
+		}
+	}
+	if wrong == "" {
+		return nil
+	}
+	return fs.caseError(op, wrong, actual, err)
+}
+
+// caseError reports a case violation, then returns a *CaseConflictError
+// if `err` is ErrCaseConflict, or `err` wrapped in a *os.PathError otherwise.
+func (fs *FS) caseError(op string, wrong string, actual string, err error) error {
+	fs.violations.report(CaseViolation{
+		Op:     op,
+		Path:   wrong,
+		Actual: actual,
+	})
+
+	if err == ErrCaseConflict {
+		return &CaseConflictError{
+			Op:     op,
+			Path:   wrong,
+			Actual: actual,
+		}
+	}
+	return wrap(err, op, wrong)
+}
+
+func (fs *FS) TrueBaseName(name string) string {
+	return fs.backend.TrueBaseName(name)
+}
+
+// IsCaseInsensitiveFS returns what the backend reports for the whole
+// filesystem. See IsCaseInsensitiveDir for a per-directory answer.
+func (fs *FS) IsCaseInsensitiveFS() bool {
+	return fs.backend.IsCaseInsensitive()
+}
+
+func (fs *FS) Create(name string) (f File, err error) {
+	rec, err := fs.begin("screw.Create", slog.String("path", name))
+	defer doneWith(rec, &f, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.openFile("screw.Create", name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(name string) (f File, err error) {
+	rec, err := fs.begin("screw.Open", slog.String("path", name))
+	defer doneWith(rec, &f, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.openFile("screw.Open", name, os.O_RDONLY, 0)
+}
+
+func (fs *FS) Symlink(oldname string, newname string) (err error) {
+	rec, err := fs.begin("screw.Symlink", slog.String("oldname", oldname), slog.String("newname", newname))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.checkPortable("screw.Symlink", newname); err != nil {
+		return err
+	}
+	if newname, err = fs.resolveCase("screw.Symlink", newname); err != nil {
+		return err
+	}
+
+	defer fs.listings.forget(newname)
+	return fs.backend.Symlink(oldname, newname)
+}
+
+func (fs *FS) Truncate(name string, size int64) (err error) {
+	rec, err := fs.begin("screw.Truncate", slog.String("path", name), slog.Int64("size", size))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if name, err = fs.resolveCase("screw.Truncate", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.Truncate", name, ErrCaseConflict); err != nil {
+		return err
+	}
+
+	return fs.backend.Truncate(name, size)
+}
+
+func (fs *FS) Chmod(name string, mode os.FileMode) (err error) {
+	rec, err := fs.begin("screw.Chmod", slog.String("path", name), modeAttr("mode", mode))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if name, err = fs.resolveCase("screw.Chmod", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.Chmod", name, os.ErrNotExist); err != nil {
+		return err
+	}
+
+	return fs.backend.Chmod(name, mode)
+}
+
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) (err error) {
+	rec, err := fs.begin("screw.Chtimes", slog.String("path", name), slog.Time("atime", atime), slog.Time("mtime", mtime))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if name, err = fs.resolveCase("screw.Chtimes", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.Chtimes", name, os.ErrNotExist); err != nil {
+		return err
+	}
+
+	return fs.backend.Chtimes(name, atime, mtime)
+}
+
+func (fs *FS) Readlink(name string) (s string, err error) {
+	rec, err := fs.begin("screw.Readlink", slog.String("path", name))
+	defer doneWith(rec, &s, &err)
+	if err != nil {
+		return "", err
+	}
+
+	if name, err = fs.resolveCase("screw.Readlink", name); err != nil {
+		return "", err
+	}
+	if err := fs.checkCase("screw.Readlink", name, os.ErrNotExist); err != nil {
+		return "", err
+	}
+
+	return fs.backend.Readlink(name)
+}
+
+func (fs *FS) ReadDir(dirname string) (e []os.FileInfo, err error) {
+	rec, err := fs.begin("screw.ReadDir", slog.String("path", dirname))
+	defer doneWith(rec, &e, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.readDir(dirname)
+}
+
+// readDir follows the logic of ioutil.ReadDir
+func (fs *FS) readDir(dirname string) ([]os.FileInfo, error) {
+	f, err := fs.openFile("screw.ReadDir", dirname, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	list, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (fs *FS) ReadFile(filename string) (data []byte, err error) {
+	rec, err := fs.begin("screw.ReadFile", slog.String("path", filename))
+	defer doneWith(rec, &data, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.openFile("screw.ReadFile", filename, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (fs *FS) WriteFile(filename string, data []byte, perm os.FileMode) (err error) {
+	rec, err := fs.begin("screw.WriteFile", slog.String("path", filename), modeAttr("perm", perm))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	f, err := fs.openFile("screw.WriteFile", filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (fs *FS) Mkdir(name string, perm os.FileMode) (err error) {
+	rec, err := fs.begin("screw.Mkdir", slog.String("path", name), modeAttr("perm", perm))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.checkPortable("screw.Mkdir", name); err != nil {
+		return err
+	}
+	if name, err = fs.resolveCase("screw.Mkdir", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.Mkdir", name, ErrCaseConflict); err != nil {
+		return err
+	}
+
+	defer fs.listings.forget(name)
+	return fs.backend.Mkdir(name, perm)
+}
+
+func (fs *FS) MkdirAll(name string, perm os.FileMode) (err error) {
+	rec, err := fs.begin("screw.MkdirAll", slog.String("path", name), modeAttr("perm", perm))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.checkPortable("screw.MkdirAll", name); err != nil {
+		return err
+	}
+	if name, err = fs.resolveCase("screw.MkdirAll", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.MkdirAll", name, ErrCaseConflict); err != nil {
+		return err
+	}
+
+	defer fs.listings.forget(name)
+	return fs.backend.MkdirAll(name, perm)
+}
+
+func (fs *FS) OpenFile(name string, flag int, perm os.FileMode) (f File, err error) {
+	rec, err := fs.begin("screw.OpenFile", slog.String("path", name), flagAttr(flag), modeAttr("perm", perm))
+	defer doneWith(rec, &f, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.openFile("screw.OpenFile", name, flag, perm)
+}
+
+func (fs *FS) openFile(op string, name string, flag int, perm os.FileMode) (File, error) {
+	caseErr := os.ErrNotExist
+	if (flag & os.O_CREATE) > 0 {
+		if err := fs.checkPortable(op, name); err != nil {
+			return nil, err
+		}
+		caseErr = ErrCaseConflict
+		defer fs.listings.forget(name)
+	}
+
+	switch fs.caseMode {
+	case CasePassthrough:
+		return fs.backend.OpenFile(name, flag, perm)
+	case CaseLenient:
+		name, err := fs.resolveCase(op, name)
+		if err != nil {
+			return nil, err
+		}
+		return fs.backend.OpenFile(name, flag, perm)
+	case CaseStrict:
+		// parents are checked by path, the file itself by handle, below
+		if err := fs.checkCase(op, filepath.Dir(name), caseErr); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checking the case and then opening would be racy: a case variant
+	// could be created, or the file renamed, in-between. Instead, open
+	// first, then check the case through the handle.
+	//
+	// Truncation is delayed until the case is verified, otherwise we
+	// could truncate a case variant before finding out it's the wrong file.
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	truncate := writable && flag&os.O_TRUNC != 0
+	if truncate {
+		flag &^= os.O_TRUNC
+	}
+
+	f, created, err := fs.openOrCreate(name, flag, perm)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			// maybe that's a case variant? (if it's a directory,
+			// that's EISDIR rather than EEXIST)
+			if err := fs.checkCase(op, name, caseErr); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if !created {
+		err = fs.verifyOpened(op, name, f, caseErr)
+		if err == nil && truncate {
+			err = f.Truncate(0)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// how many times to retry opening a file that keeps getting
+// removed between our exclusive create and our open
+const maxOpenAttempts = 3
+
+// openOrCreate opens `name`. When O_CREATE is passed without O_EXCL, it
+// first tries to create it exclusively: if that works, no case variant
+// can exist, so the case doesn't need verifying.
+func (fs *FS) openOrCreate(name string, flag int, perm os.FileMode) (f File, created bool, err error) {
+	if flag&os.O_CREATE == 0 || flag&os.O_EXCL != 0 {
+		f, err = fs.backend.OpenFile(name, flag, perm)
+		return f, false, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		f, err = fs.backend.OpenFile(name, flag|os.O_EXCL, perm)
+		if err == nil {
+			return f, true, nil
+		}
+		if !os.IsExist(err) {
+			return nil, false, err
+		}
+
+		// already exists (maybe with a different case)
+		f, err = fs.backend.OpenFile(name, flag&^os.O_CREATE, perm)
+		if os.IsNotExist(err) && attempt < maxOpenAttempts {
+			// removed in-between, try again
+			continue
+		}
+		return f, false, err
+	}
+}
+
+// verifyOpened checks the case of an opened file through its handle,
+// which can't race with renames.
+func (fs *FS) verifyOpened(op string, name string, f File, caseErr error) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil
+	}
+
+	requested := filepath.Base(abs)
+	trueBase := fs.backend.FileTrueBaseName(f)
+	if trueBase == "" || fs.sameName(trueBase, requested) {
+		return nil
+	}
+	if !equalFold(trueBase, requested) {
+		// opened through a symlink or a hard link: the handle doesn't
+		// tell us the name we opened it by, so check the path instead.
+		return fs.checkCase(op, name, caseErr)
+	}
+	return fs.caseError(op, name, filepath.Join(filepath.Dir(name), trueBase), caseErr)
+}
+
+func (fs *FS) Stat(name string) (s os.FileInfo, err error) {
+	rec, err := fs.begin("screw.Stat", slog.String("path", name))
+	defer doneWith(rec, &s, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, err = fs.resolveCase("screw.Stat", name); err != nil {
+		return nil, err
+	}
+	if err := fs.checkCase("screw.Stat", name, os.ErrNotExist); err != nil {
+		return nil, err
+	}
+
+	return fs.backend.Stat(name)
+}
+
+func (fs *FS) Lstat(name string) (s os.FileInfo, err error) {
+	rec, err := fs.begin("screw.Lstat", slog.String("path", name))
+	defer doneWith(rec, &s, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, err = fs.resolveCase("screw.Lstat", name); err != nil {
+		return nil, err
+	}
+	if err := fs.checkCase("screw.Lstat", name, os.ErrNotExist); err != nil {
+		return nil, err
+	}
+
+	return fs.backend.Lstat(name)
+}
+
+func (fs *FS) RemoveAll(name string) error {
+	return fs.RemoveAllContext(context.Background(), name)
+}
+
+// RemoveAllContext is like RemoveAll, but stops retrying as soon as
+// ctx is done, returning ctx.Err() joined with the last error encountered.
+func (fs *FS) RemoveAllContext(ctx context.Context, name string) (err error) {
+	rec, err := fs.begin("screw.RemoveAll", slog.String("path", name))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	ctx = rec.context(ctx)
+
+	if name, err = fs.resolveCase("screw.RemoveAll", name); err != nil {
+		return err
+	}
+	if fs.checkCase("screw.RemoveAll", name, os.ErrNotExist) != nil {
+		// asked to remove "apricot" but "APRICOT" (or another case variant)
+		// exists, consider already removed
+		return nil
+	}
+
+	defer fs.forgetCase(name)
+
+	// the backend's RemoveAll doesn't follow a directory that's replaced
+	// by a symlink while it's being removed, so it's only given up on
+	// when something in the tree is locked.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err = fs.backend.RemoveAll(name)
+	if err == nil || !fs.retry.retryable(err) {
+		return err
+	}
+	return fs.removeAll(ctx, name)
+}
+
+// removeAll removes `name` and its children one entry at a time, so
+// that an entry that's temporarily locked is retried on its own,
+// without starting over.
+func (fs *FS) removeAll(ctx context.Context, name string) error {
+	// same as os.RemoveAll
+	if name == "" {
+		return nil
+	}
+	if filepath.Base(name) == "." {
+		return &os.PathError{Op: "RemoveAll", Path: name, Err: syscall.EINVAL}
+	}
+
+	stats, err := fs.backend.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var firstErr error
+	if stats.IsDir() {
+		names, err := fs.readDirNames(ctx, name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, child := range names {
+			if err := fs.removeAll(ctx, filepath.Join(name, child)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				if errors.Is(firstErr, ctxErr) {
+					return firstErr
+				}
+				return errors.Join(firstErr, ctxErr)
+			}
+		}
+	}
+
+	err = fs.remove(ctx, name)
+	if err != nil && !os.IsNotExist(err) && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// readDirNames lists the entries of a directory through the backend,
+// retrying according to the retry policy.
+func (fs *FS) readDirNames(ctx context.Context, name string) ([]string, error) {
+	var names []string
+	err := fs.retryOp(ctx, func() error {
+		f, err := fs.backend.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		names, err = f.Readdirnames(-1)
+		return err
+	})
+	return names, err
+}
+
+func (fs *FS) Remove(name string) error {
+	return fs.RemoveContext(context.Background(), name)
+}
+
+// RemoveContext is like Remove, but stops retrying as soon as ctx is
+// done, returning ctx.Err() joined with the last error encountered.
+func (fs *FS) RemoveContext(ctx context.Context, name string) (err error) {
+	rec, err := fs.begin("screw.Remove", slog.String("path", name))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	ctx = rec.context(ctx)
+
+	if name, err = fs.resolveCase("screw.Remove", name); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.Remove", name, os.ErrNotExist); err != nil {
+		// asked to remove "apricot" but "APRICOT" (or another case variant)
+		// exists, so, can't remove "apricot" because it doesn't exist
+		return err
+	}
+
+	// accepting to try and remove "apricot"
+	defer fs.forgetCase(name)
+	return fs.remove(ctx, name)
+}
+
+func (fs *FS) Rename(oldpath, newpath string) error {
+	return fs.RenameContext(context.Background(), oldpath, newpath)
+}
+
+// RenameContext is like Rename, but stops retrying as soon as ctx is
+// done, returning ctx.Err() joined with the last error encountered.
+func (fs *FS) RenameContext(ctx context.Context, oldpath, newpath string) (err error) {
+	rec, err := fs.begin("screw.Rename", slog.String("oldpath", oldpath), slog.String("newpath", newpath))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	ctx = rec.context(ctx)
+
+	if err := fs.checkPortable("screw.Rename", newpath); err != nil {
+		return err
+	}
+	if oldpath, err = fs.resolveCase("screw.Rename", oldpath); err != nil {
+		return err
+	}
+	if newpath, err = fs.resolveDest("screw.Rename", newpath, oldpath); err != nil {
+		return err
+	}
+
+	defer fs.forgetCase(oldpath)
+	defer fs.forgetCase(newpath)
+	err = fs.rename(ctx, oldpath, newpath)
+	if err != nil {
+		return err
+	}
+
+	// case-only rename?
+	if equalFold(oldpath, newpath) && fs.caseInsensitiveAt(newpath, true) {
+		// was it changed properly?
+		if !fs.sameName(fs.backend.TrueBaseName(newpath), filepath.Base(newpath)) {
+			tmppath := oldpath + fmt.Sprintf("_rename_%d", os.Getpid())
+			err := fs.rename(ctx, oldpath, tmppath)
+			if err != nil {
+				// here is an awkward place to return an error, but
+				// if anyone has a better idea, I'm listening.. :(
+				return err
+			}
+
+			err = fs.rename(ctx, tmppath, newpath)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// remove removes through the backend, retrying according to
+// the retry policy.
+func (fs *FS) remove(ctx context.Context, name string) error {
+	return fs.retryOp(ctx, func() error {
+		return fs.backend.Remove(name)
+	})
+}
+
+// rename renames through the backend, retrying according to
+// the retry policy.
+func (fs *FS) rename(ctx context.Context, oldpath, newpath string) error {
+	return fs.retryOp(ctx, func() error {
+		return fs.backend.Rename(oldpath, newpath)
+	})
+}
+
+// retryOp calls fn until it succeeds, fails with an error that isn't
+// worth retrying, or the retry policy gives up. If ctx is done first,
+// it returns ctx.Err() joined with the last error fn returned.
+func (fs *FS) retryOp(ctx context.Context, fn func() error) error {
+	sleeper := fs.newSleeper()
+	var err error
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Join(ctxErr, err)
+		}
+		err = fn()
+		if err == nil || !fs.retry.retryable(err) {
+			return err
+		}
+		if !sleeper.Sleep(ctx, err) {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return errors.Join(ctxErr, err)
+			}
+			return err
+		}
+		if rec := recordFrom(ctx); rec != nil {
+			rec.event.Retries++
+		}
+	}
+}
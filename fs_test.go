@@ -0,0 +1,106 @@
+package screw_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FS_CaseModes(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("APRICOT"), "hello")
+
+	sensible := screw.New(screw.WithBackend(mem))
+	passthrough := screw.New(screw.WithBackend(mem), screw.WithCaseMode(screw.CasePassthrough))
+
+	_, err := sensible.Stat(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+
+	_, err = passthrough.Stat(memPath("apricot"))
+	assert.NoError(err)
+
+	// IsWrongCase answers truthfully regardless of the mode
+	assert.True(passthrough.IsWrongCase(memPath("apricot")))
+}
+
+func newBufferLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func Test_FS_Logger(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	mem := screw.NewMemBackend()
+	fs := screw.New(screw.WithBackend(mem), screw.WithLogger(newBufferLogger(&buf)))
+
+	_, err := fs.Stat(memPath("apricot"))
+	assert.Error(err)
+
+	var record map[string]any
+	must(json.Unmarshal(buf.Bytes(), &record))
+	assert.EqualValues("screw.Stat", record["msg"])
+	assert.EqualValues("DEBUG", record["level"])
+	assert.EqualValues(memPath("apricot"), record["path"])
+	assert.Contains(record["error"], "does not exist")
+	assert.Contains(record, "duration")
+	assert.EqualValues(0, record["retries"])
+	assert.NotContains(record, "stack", "stack traces are opt-in")
+
+	// one record per operation
+	buf.Reset()
+	must(fs.MkdirAll(memPath(), 0o755))
+	must(fs.WriteFile(memPath("apricot"), []byte("apricot"), 0o644))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 2)
+
+	record = nil
+	must(json.Unmarshal([]byte(lines[1]), &record))
+	assert.EqualValues("screw.WriteFile", record["msg"])
+	assert.EqualValues("0644", record["perm"])
+	assert.NotContains(record, "error")
+
+	// records are discarded by loggers that don't want debug output
+	buf.Reset()
+	quiet := screw.New(screw.WithBackend(mem), screw.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	_, err = quiet.Stat(memPath("apricot"))
+	must(err)
+	assert.Empty(buf.String())
+}
+
+func Test_FS_Logger_Retries(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = isLocked
+	policy.Clock = &fakeClock{}
+	fsys, _ := newFlakyFS(2, policy)
+	fsys = fsys.With(screw.WithLogger(newBufferLogger(&buf)), screw.WithStackTraces(true))
+
+	must(fsys.Rename(memPath("apricot"), memPath("banana")))
+
+	var record map[string]any
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	must(json.Unmarshal([]byte(lines[len(lines)-1]), &record))
+	assert.EqualValues("screw.Rename", record["msg"])
+	assert.EqualValues(memPath("apricot"), record["oldpath"])
+	assert.EqualValues(memPath("banana"), record["newpath"])
+	assert.EqualValues(2, record["retries"])
+	assert.Contains(record["stack"], "Test_FS_Logger_Retries")
+}
+
+func Test_FS_Default(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.IsType(screw.OSBackend{}, screw.Default().Backend())
+}
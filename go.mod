@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/sys v0.41.0
+	golang.org/x/text v0.34.0
 )
 
 require (
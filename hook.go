@@ -0,0 +1,94 @@
+package screw
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Event describes an operation going through an FS, see Hook
+type Event struct {
+	// Op is the name of the operation, like "screw.Stat"
+	Op string
+
+	// Args are the arguments of the operation, by name: "path", or
+	// "oldpath" and "newpath", "flag", "perm", etc.
+	Args []slog.Attr
+
+	// Result is what the operation returned besides an error, if
+	// anything (a File, an os.FileInfo, etc.). Only set in After,
+	// when Err is nil.
+	Result any
+
+	// Err is the error the operation returned. Only set in After.
+	Err error
+
+	// Duration is how long the operation took. Only set in After.
+	Duration time.Duration
+
+	// Retries is how many times the operation was retried, see
+	// RetryPolicy. Only set in After.
+	Retries int
+}
+
+// Arg returns the value of the argument named `key`, and
+// false if there's no such argument.
+func (ev *Event) Arg(key string) (slog.Value, bool) {
+	for _, attr := range ev.Args {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
+// Path returns the "path" argument of the event, or "" if it has none
+func (ev *Event) Path() string {
+	if v, ok := ev.Arg("path"); ok {
+		return v.String()
+	}
+	return ""
+}
+
+// A Hook observes, and may intercept, every operation of an FS.
+//
+// Before is called before the operation does anything. If it returns
+// an error, the operation fails with that error, which makes hooks
+// usable for fault injection and policy checks.
+//
+// After is called once the operation is done, even when a Before
+// returned an error, but only for hooks whose Before was called: if
+// a hook's Before fails, the hooks added after it see neither.
+type Hook interface {
+	Before(ev *Event) error
+	After(ev *Event)
+}
+
+// HookFuncs is a Hook made of functions, either of which can be nil
+type HookFuncs struct {
+	BeforeFunc func(ev *Event) error
+	AfterFunc  func(ev *Event)
+}
+
+var _ Hook = HookFuncs{}
+
+func (hf HookFuncs) Before(ev *Event) error {
+	if hf.BeforeFunc == nil {
+		return nil
+	}
+	return hf.BeforeFunc(ev)
+}
+
+func (hf HookFuncs) After(ev *Event) {
+	if hf.AfterFunc != nil {
+		hf.AfterFunc(ev)
+	}
+}
+
+// WithHook adds a hook to an FS. Hooks' Before methods are called in
+// the order they were added, and their After methods in reverse order.
+// To hook package-level functions, see SetDefault.
+func WithHook(hook Hook) Option {
+	return func(fs *FS) {
+		fs.hooks = append(fs.hooks[:len(fs.hooks):len(fs.hooks)], hook)
+	}
+}
@@ -0,0 +1,202 @@
+package screw_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHook keeps every event it sees, and logs the order
+// it's called in
+type recordingHook struct {
+	name   string
+	calls  *[]string
+	events []screw.Event
+}
+
+func (rh *recordingHook) Before(ev *screw.Event) error {
+	*rh.calls = append(*rh.calls, rh.name+".Before "+ev.Op)
+	return nil
+}
+
+func (rh *recordingHook) After(ev *screw.Event) {
+	*rh.calls = append(*rh.calls, rh.name+".After "+ev.Op)
+	rh.events = append(rh.events, *ev)
+}
+
+func Test_Hook_Events(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls []string
+	first := &recordingHook{name: "first", calls: &calls}
+	second := &recordingHook{name: "second", calls: &calls}
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	fsys := screw.New(screw.WithBackend(mem), screw.WithHook(first), screw.WithHook(second))
+
+	must(fsys.WriteFile(memPath("apricot"), []byte("apricot"), 0o644))
+	stats, err := fsys.Stat(memPath("apricot"))
+	must(err)
+	_, err = fsys.Stat(memPath("APRICOT"))
+	assert.True(os.IsNotExist(err))
+	must(fsys.Rename(memPath("apricot"), memPath("banana")))
+
+	assert.EqualValues([]string{
+		"first.Before screw.WriteFile",
+		"second.Before screw.WriteFile",
+		"second.After screw.WriteFile",
+		"first.After screw.WriteFile",
+		"first.Before screw.Stat",
+		"second.Before screw.Stat",
+		"second.After screw.Stat",
+		"first.After screw.Stat",
+		"first.Before screw.Stat",
+		"second.Before screw.Stat",
+		"second.After screw.Stat",
+		"first.After screw.Stat",
+		"first.Before screw.Rename",
+		"second.Before screw.Rename",
+		"second.After screw.Rename",
+		"first.After screw.Rename",
+	}, calls)
+
+	events := first.events
+	if assert.Len(events, 4) {
+		assert.EqualValues(memPath("apricot"), events[0].Path())
+		perm, ok := events[0].Arg("perm")
+		assert.True(ok)
+		assert.EqualValues("0644", perm.String())
+
+		assert.NoError(events[1].Err)
+		assert.Same(stats, events[1].Result)
+
+		assert.True(os.IsNotExist(events[2].Err))
+		assert.Nil(events[2].Result)
+
+		oldpath, _ := events[3].Arg("oldpath")
+		newpath, _ := events[3].Arg("newpath")
+		assert.EqualValues(memPath("apricot"), oldpath.String())
+		assert.EqualValues(memPath("banana"), newpath.String())
+	}
+}
+
+func Test_Hook_FaultInjection(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("apricot"), "apricot")
+
+	var after *screw.Event
+	fsys := screw.New(screw.WithBackend(mem), screw.WithHook(screw.HookFuncs{
+		BeforeFunc: func(ev *screw.Event) error {
+			if ev.Op == "screw.Remove" {
+				return &os.PathError{Op: "remove", Path: ev.Path(), Err: syscall.EACCES}
+			}
+			return nil
+		},
+		AfterFunc: func(ev *screw.Event) {
+			after = ev
+		},
+	}))
+
+	err := fsys.Remove(memPath("apricot"))
+	assert.True(errors.Is(err, syscall.EACCES))
+	if assert.NotNil(after) {
+		assert.EqualValues("screw.Remove", after.Op)
+		assert.True(errors.Is(after.Err, syscall.EACCES), "After sees the injected error")
+	}
+	assert.EqualValues("apricot", readMem(mem, memPath("apricot")), "operation must not run")
+
+	contents, err := fsys.ReadFile(memPath("apricot"))
+	assert.NoError(err)
+	assert.EqualValues("apricot", string(contents))
+	assert.EqualValues(contents, after.Result)
+
+	// hooks added after the one that failed see neither Before nor After
+	var calls []string
+	first := &recordingHook{name: "first", calls: &calls}
+	last := &recordingHook{name: "last", calls: &calls}
+	fsys = screw.New(
+		screw.WithBackend(mem),
+		screw.WithHook(first),
+		screw.WithHook(screw.HookFuncs{BeforeFunc: func(ev *screw.Event) error {
+			return syscall.EACCES
+		}}),
+		screw.WithHook(last),
+	)
+	_, err = fsys.Stat(memPath("apricot"))
+	assert.True(errors.Is(err, syscall.EACCES))
+	assert.EqualValues([]string{"first.Before screw.Stat", "first.After screw.Stat"}, calls)
+	assert.Empty(last.events)
+}
+
+func Test_Hook_Retries(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = isLocked
+	policy.Clock = &fakeClock{}
+	fsys, _ := newFlakyFS(3, policy)
+
+	var retries int
+	fsys = fsys.With(screw.WithHook(screw.HookFuncs{
+		AfterFunc: func(ev *screw.Event) {
+			retries = ev.Retries
+		},
+	}))
+	must(fsys.Rename(memPath("apricot"), memPath("banana")))
+	assert.EqualValues(3, retries)
+}
+
+func Test_Hook_SetDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls []string
+	hook := &recordingHook{name: "hook", calls: &calls}
+	previous := screw.Default()
+	screw.SetDefault(previous.With(screw.WithHook(hook)))
+	defer screw.SetDefault(previous)
+
+	tmpFile := filepath.Join(t.TempDir(), "apricot")
+	must(screw.WriteFile(tmpFile, []byte("apricot"), 0o644))
+	_, err := screw.Stat(tmpFile)
+	assert.NoError(err)
+
+	assert.EqualValues([]string{
+		"hook.Before screw.WriteFile",
+		"hook.After screw.WriteFile",
+		"hook.Before screw.Stat",
+		"hook.After screw.Stat",
+	}, calls)
+}
+
+func Test_Hook_SetDefault_Mem(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("Apricot"), "apricot")
+	previous := screw.Default()
+	screw.SetDefault(screw.New(screw.WithBackend(mem)))
+	defer screw.SetDefault(previous)
+
+	// every package-level function goes through the default FS...
+	assert.True(screw.IsCaseInsensitiveFS())
+	assert.EqualValues("Apricot", screw.TrueBaseName(memPath("APRICOT")))
+	data, err := screw.ReadFile(memPath("Apricot"))
+	assert.NoError(err)
+	assert.EqualValues("apricot", string(data))
+
+	// ...but those that return an *os.File can't return memory files
+	_, err = screw.Open(memPath("Apricot"))
+	assert.True(errors.Is(err, screw.ErrNotOSFile), "got %+v", err)
+	_, err = screw.Create(memPath("Banana"))
+	assert.True(errors.Is(err, screw.ErrNotOSFile), "got %+v", err)
+}
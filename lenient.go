@@ -0,0 +1,243 @@
+package screw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// resolveCase returns `name`, with each component below the root (see
+// WithRoot) that doesn't exist as given replaced by its single on-disk
+// case variant. Components that don't exist in any case are kept as
+// given, and so are their children.
+//
+// It only does something in CaseLenient mode, and returns an
+// *AmbiguousCaseError if a component has several case variants.
+func (fs *FS) resolveCase(op string, name string) (string, error) {
+	if fs.caseMode != CaseLenient {
+		return name, nil
+	}
+
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name, nil
+	}
+
+	root := fs.strictRoot(abs)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// outside of the root, only resolve the base name
+		root = filepath.Dir(abs)
+		rel = filepath.Base(abs)
+	}
+	if rel == "." {
+		return name, nil
+	}
+
+	changed := false
+	current := root
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		candidate := filepath.Join(current, part)
+		_, err := fs.backend.Lstat(candidate)
+		if err == nil {
+			current = candidate
+			continue
+		}
+		if !os.IsNotExist(err) {
+			// not a directory, no permission, etc.
+			return fs.resolved(name, changed, current, parts[i:])
+		}
+
+		match, err := fs.foldLookup(op, current, part)
+		if err != nil {
+			return "", err
+		}
+		if match == "" {
+			// doesn't exist in any case, so neither do its children
+			return fs.resolved(name, changed, current, parts[i:])
+		}
+
+		fs.violations.report(CaseViolation{
+			Op:     op,
+			Path:   candidate,
+			Actual: filepath.Join(current, match),
+		})
+		changed = true
+		current = filepath.Join(current, match)
+	}
+	return fs.resolved(name, changed, current, nil)
+}
+
+func (fs *FS) resolved(name string, changed bool, current string, rest []string) (string, error) {
+	if !changed {
+		// keep relative paths relative
+		return name, nil
+	}
+	return filepath.Join(append([]string{current}, rest...)...), nil
+}
+
+// resolveDest is resolveCase for the destination of a rename from
+// `src`. If the destination resolves to `src` itself, it's a case-only
+// rename, and the new case is kept.
+func (fs *FS) resolveDest(op string, name string, src string) (string, error) {
+	if fs.caseMode != CaseLenient {
+		return name, nil
+	}
+
+	dir, err := fs.resolveCase(op, filepath.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(name)
+	dest := filepath.Join(dir, base)
+	if dir == filepath.Dir(name) {
+		// keep relative paths relative
+		dest = name
+	}
+
+	if _, err := fs.backend.Lstat(dest); !os.IsNotExist(err) {
+		return dest, nil
+	}
+	match, err := fs.foldLookup(op, dir, base)
+	if err != nil || match == "" {
+		return dest, err
+	}
+
+	absMatch, err1 := filepath.Abs(filepath.Join(dir, match))
+	absSrc, err2 := filepath.Abs(src)
+	if err1 == nil && err2 == nil && absMatch == absSrc {
+		return dest, nil
+	}
+
+	fs.violations.report(CaseViolation{
+		Op:     op,
+		Path:   dest,
+		Actual: filepath.Join(dir, match),
+	})
+	return filepath.Join(dir, match), nil
+}
+
+// foldLookup returns the entry of `dir` that's a case variant of
+// `base`, "" if there is none, or an *AmbiguousCaseError if there
+// are several.
+func (fs *FS) foldLookup(op string, dir string, base string) (string, error) {
+	key := fs.nameKey(base)
+	names, cached := fs.listings.get(dir)
+	for {
+		if !cached {
+			var err error
+			names, err = fs.listNames(dir)
+			if err != nil {
+				// can't list it, nothing to resolve
+				return "", nil
+			}
+			fs.listings.put(dir, names)
+		}
+
+		var matches []string
+		for _, name := range names {
+			if fs.nameKey(name) == key {
+				matches = append(matches, name)
+			}
+		}
+
+		if cached {
+			// the listing may be stale, only trust it if it gives
+			// a single match that still exists.
+			stale := len(matches) != 1
+			if !stale {
+				_, err := fs.backend.Lstat(filepath.Join(dir, matches[0]))
+				stale = err != nil
+			}
+			if stale {
+				cached = false
+				continue
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return "", nil
+		case 1:
+			return matches[0], nil
+		default:
+			candidates := make([]string, len(matches))
+			for i, match := range matches {
+				candidates[i] = filepath.Join(dir, match)
+			}
+			return "", &AmbiguousCaseError{
+				Op:         op,
+				Path:       filepath.Join(dir, base),
+				Candidates: candidates,
+			}
+		}
+	}
+}
+
+func (fs *FS) listNames(dir string) ([]string, error) {
+	f, err := fs.backend.OpenFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// listingCache holds directory listings for CaseLenient, by
+// absolute path
+type listingCache struct {
+	mu   sync.Mutex
+	dirs map[string][]string
+}
+
+func newListingCache() *listingCache {
+	return &listingCache{
+		dirs: make(map[string][]string),
+	}
+}
+
+func (lc *listingCache) get(dir string) ([]string, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	names, ok := lc.dirs[dir]
+	return names, ok
+}
+
+func (lc *listingCache) put(dir string, names []string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.dirs[dir] = names
+}
+
+// forget drops the listing of the directory containing `name`, and
+// the listings of `name` and everything below it, in any case.
+func (lc *listingCache) forget(name string) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		lc.clear()
+		return
+	}
+	prefix := foldName(abs)
+	parent := foldName(filepath.Dir(abs))
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for dir := range lc.dirs {
+		folded := foldName(dir)
+		if folded == parent || folded == prefix || strings.HasPrefix(folded, prefix+string(os.PathSeparator)) {
+			delete(lc.dirs, dir)
+		}
+	}
+}
+
+func (lc *listingCache) clear() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	clear(lc.dirs)
+}
@@ -0,0 +1,153 @@
+package screw_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// caseSensitiveTempDir returns a temporary directory, skipping the
+// test if it's case-insensitive: lenient mode is a no-op there.
+func caseSensitiveTempDir(t *testing.T) string {
+	tmpDir := t.TempDir()
+	insensitive, err := screw.IsCaseInsensitiveDir(tmpDir)
+	must(err)
+	if insensitive {
+		t.Skip("needs a case-sensitive temporary directory")
+	}
+	return tmpDir
+}
+
+func Test_Lenient_Resolve(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := caseSensitiveTempDir(t)
+	must(os.MkdirAll(filepath.Join(tmpDir, "data", "textures"), 0o755))
+	must(os.WriteFile(filepath.Join(tmpDir, "data", "textures", "foo.png"), []byte("foo"), 0o644))
+
+	var violations []screw.CaseViolation
+	lenient := screw.New(
+		screw.WithCaseMode(screw.CaseLenient),
+		screw.WithRoot(tmpDir),
+		screw.WithCaseViolationHandler(func(v screw.CaseViolation) {
+			violations = append(violations, v)
+		}),
+	)
+	windowsPath := filepath.Join(tmpDir, "Data", "Textures", "Foo.PNG")
+
+	contents, err := lenient.ReadFile(windowsPath)
+	assert.NoError(err)
+	assert.EqualValues("foo", string(contents))
+
+	stats, err := lenient.Stat(windowsPath)
+	if assert.NoError(err) {
+		assert.EqualValues("foo.png", stats.Name())
+	}
+
+	f, err := lenient.Open(windowsPath)
+	if assert.NoError(err) {
+		assert.EqualValues(filepath.Join(tmpDir, "data", "textures", "foo.png"), f.Name())
+		f.Close()
+	}
+
+	entries, err := lenient.ReadDir(filepath.Join(tmpDir, "DATA", "textures"))
+	if assert.NoError(err) && assert.Len(entries, 1) {
+		assert.EqualValues("foo.png", entries[0].Name())
+	}
+
+	if assert.NotEmpty(violations) {
+		assert.EqualValues(screw.CaseViolation{
+			Op:     "screw.ReadFile",
+			Path:   filepath.Join(tmpDir, "Data"),
+			Actual: filepath.Join(tmpDir, "data"),
+		}, violations[0])
+	}
+
+	// writes go to the existing file, instead of creating a variant
+	must(lenient.WriteFile(windowsPath, []byte("bar"), 0o644))
+	names, err := os.ReadDir(filepath.Join(tmpDir, "data", "textures"))
+	must(err)
+	assert.Len(names, 1)
+	contents, err = os.ReadFile(filepath.Join(tmpDir, "data", "textures", "foo.png"))
+	must(err)
+	assert.EqualValues("bar", string(contents))
+
+	// components that don't exist in any case are kept as given
+	must(lenient.MkdirAll(filepath.Join(tmpDir, "DATA", "Sounds"), 0o755))
+	_, err = os.Stat(filepath.Join(tmpDir, "data", "Sounds"))
+	assert.NoError(err)
+
+	_, err = lenient.Stat(filepath.Join(tmpDir, "Data", "Nope", "foo.png"))
+	assert.True(os.IsNotExist(err))
+
+	// case-only renames keep the new case
+	must(lenient.Rename(filepath.Join(tmpDir, "data", "textures", "foo.png"), filepath.Join(tmpDir, "data", "textures", "FOO.png")))
+	_, err = os.Stat(filepath.Join(tmpDir, "data", "textures", "FOO.png"))
+	assert.NoError(err)
+
+	// the root itself isn't resolved
+	_, err = lenient.Stat(filepath.Join(tmpDir+"-nope", "data"))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_Lenient_Ambiguous(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := caseSensitiveTempDir(t)
+	must(os.WriteFile(filepath.Join(tmpDir, "foo.png"), []byte("lower"), 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "FOO.png"), []byte("upper"), 0o644))
+
+	lenient := screw.New(screw.WithCaseMode(screw.CaseLenient), screw.WithRoot(tmpDir))
+
+	_, err := lenient.ReadFile(filepath.Join(tmpDir, "Foo.png"))
+	assert.True(screw.IsAmbiguousCase(err))
+	var ace *screw.AmbiguousCaseError
+	if assert.True(errors.As(err, &ace)) {
+		assert.EqualValues("screw.ReadFile", ace.Op)
+		assert.EqualValues(filepath.Join(tmpDir, "Foo.png"), ace.Path)
+		assert.ElementsMatch([]string{
+			filepath.Join(tmpDir, "foo.png"),
+			filepath.Join(tmpDir, "FOO.png"),
+		}, ace.Candidates)
+	}
+
+	// exact matches aren't ambiguous
+	contents, err := lenient.ReadFile(filepath.Join(tmpDir, "FOO.png"))
+	assert.NoError(err)
+	assert.EqualValues("upper", string(contents))
+}
+
+func Test_Lenient_Cache(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := caseSensitiveTempDir(t)
+	must(os.WriteFile(filepath.Join(tmpDir, "foo.png"), []byte("foo"), 0o644))
+
+	pb := &probingBackend{}
+	lenient := screw.New(screw.WithBackend(pb), screw.WithCaseMode(screw.CaseLenient), screw.WithRoot(tmpDir))
+
+	_, err := lenient.ReadFile(filepath.Join(tmpDir, "FOO.PNG"))
+	must(err)
+	assert.EqualValues(2, pb.opens, "lists the directory, then opens the file")
+
+	pb.opens = 0
+	_, err = lenient.ReadFile(filepath.Join(tmpDir, "FOO.PNG"))
+	must(err)
+	assert.EqualValues(1, pb.opens, "listing must be cached")
+
+	// files created behind the FS's back are found
+	must(os.WriteFile(filepath.Join(tmpDir, "bar.png"), []byte("bar"), 0o644))
+	contents, err := lenient.ReadFile(filepath.Join(tmpDir, "BAR.PNG"))
+	assert.NoError(err)
+	assert.EqualValues("bar", string(contents))
+
+	// and so are cached matches renamed behind the FS's back
+	must(os.Rename(filepath.Join(tmpDir, "foo.png"), filepath.Join(tmpDir, "fOO.png")))
+	contents, err = lenient.ReadFile(filepath.Join(tmpDir, "FOO.PNG"))
+	assert.NoError(err)
+	assert.EqualValues("foo", string(contents))
+}
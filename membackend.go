@@ -0,0 +1,765 @@
+package screw
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemBackend is an in-memory Backend with case-preserving,
+// case-insensitive semantics, like NTFS or APFS. It lets the CPCI
+// behavior of screw be exercised on any OS, Linux included.
+//
+// Relative paths are resolved against the process working directory,
+// like the os package does, but nothing on disk is ever touched.
+type MemBackend struct {
+	mu   sync.Mutex
+	root *memNode
+}
+
+var _ Backend = (*MemBackend)(nil)
+
+type memNode struct {
+	// on-disk name, ie. the casing the node was created with
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	data     []byte
+	target   string
+	parent   *memNode
+	children map[string]*memNode
+}
+
+// maximum number of symlinks followed when resolving a single path
+const memMaxLinks = 40
+
+func NewMemBackend() *MemBackend {
+	return &MemBackend{
+		root: &memNode{
+			name:     string(filepath.Separator),
+			mode:     os.ModeDir | 0o755,
+			modTime:  time.Now(),
+			children: make(map[string]*memNode),
+		},
+	}
+}
+
+func memKey(name string) string {
+	return foldName(name)
+}
+
+func memSplit(name string) ([]string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	abs = abs[len(filepath.VolumeName(abs)):]
+
+	var parts []string
+	for _, part := range strings.Split(abs, string(filepath.Separator)) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts, nil
+}
+
+func (n *memNode) isDir() bool {
+	return n.mode.IsDir()
+}
+
+func (n *memNode) isSymlink() bool {
+	return n.mode&os.ModeSymlink != 0
+}
+
+func (n *memNode) path() string {
+	var parts []string
+	for node := n; node.parent != nil; node = node.parent {
+		parts = append([]string{node.name}, parts...)
+	}
+	return string(filepath.Separator) + filepath.Join(parts...)
+}
+
+func (n *memNode) info(name string) os.FileInfo {
+	return &memFileInfo{
+		name:    name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+func (n *memNode) sortedChildren() []*memNode {
+	children := make([]*memNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// resolve looks up `name`. Symlinks in parent position are always
+// followed, a symlink in last position is only followed if `follow` is set.
+func (m *MemBackend) resolve(name string, follow bool) (*memNode, error) {
+	parts, err := memSplit(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.walk(parts, follow, 0)
+}
+
+func (m *MemBackend) walk(parts []string, follow bool, links int) (*memNode, error) {
+	node := m.root
+	for i, part := range parts {
+		if !node.isDir() {
+			return nil, syscall.ENOTDIR
+		}
+
+		child, ok := node.children[memKey(part)]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		last := i == len(parts)-1
+		if child.isSymlink() && (follow || !last) {
+			if links >= memMaxLinks {
+				return nil, syscall.ELOOP
+			}
+			target := child.target
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(node.path(), target)
+			}
+			targetParts, err := memSplit(target)
+			if err != nil {
+				return nil, err
+			}
+			return m.walk(append(targetParts, parts[i+1:]...), follow, links+1)
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// parent resolves the directory `name` would live in, and returns it
+// along with the base name.
+func (m *MemBackend) parent(name string) (*memNode, string, error) {
+	parts, err := memSplit(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(parts) == 0 {
+		return nil, "", os.ErrExist
+	}
+
+	dir, err := m.walk(parts[:len(parts)-1], true, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	if !dir.isDir() {
+		return nil, "", syscall.ENOTDIR
+	}
+	return dir, parts[len(parts)-1], nil
+}
+
+func (m *MemBackend) create(dir *memNode, base string, mode os.FileMode) *memNode {
+	node := &memNode{
+		name:    base,
+		mode:    mode,
+		modTime: time.Now(),
+		parent:  dir,
+	}
+	if mode.IsDir() {
+		node.children = make(map[string]*memNode)
+	}
+	dir.children[memKey(base)] = node
+	dir.modTime = node.modTime
+	return node
+}
+
+func (m *MemBackend) detach(node *memNode) {
+	delete(node.parent.children, memKey(node.name))
+	node.parent.modTime = time.Now()
+}
+
+func (m *MemBackend) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wrap := func(err error) error {
+		return &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	node, err := m.resolve(name, true)
+	if err == nil {
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, wrap(os.ErrExist)
+		}
+		if node.isDir() && writable {
+			return nil, wrap(syscall.EISDIR)
+		}
+		if flag&os.O_TRUNC != 0 && writable {
+			node.data = nil
+			node.modTime = time.Now()
+		}
+	} else {
+		if !os.IsNotExist(err) || flag&os.O_CREATE == 0 {
+			return nil, wrap(err)
+		}
+
+		dir, base, err := m.parent(name)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		if _, ok := dir.children[memKey(base)]; ok {
+			// dangling symlink
+			return nil, wrap(os.ErrNotExist)
+		}
+		node = m.create(dir, base, perm.Perm())
+	}
+
+	return &memFile{
+		m:    m,
+		node: node,
+		name: name,
+		flag: flag,
+	}, nil
+}
+
+func (m *MemBackend) Stat(name string) (os.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+func (m *MemBackend) Lstat(name string) (os.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *MemBackend) stat(op string, name string, follow bool) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, follow)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return node.info(filepath.Base(name)), nil
+}
+
+func (m *MemBackend) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.parent(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if _, ok := dir.children[memKey(base)]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	m.create(dir, base, os.ModeDir|perm.Perm())
+	return nil
+}
+
+// MkdirAll follows the logic of os.MkdirAll
+func (m *MemBackend) MkdirAll(name string, perm os.FileMode) error {
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+
+	stats, err := m.Stat(name)
+	if err == nil {
+		if stats.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	parent := filepath.Dir(name)
+	if parent != name {
+		err = m.MkdirAll(parent, perm)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.Mkdir(name, perm)
+	if err != nil {
+		stats, statErr := m.Lstat(name)
+		if statErr == nil && stats.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *MemBackend) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wrap := func(err error) error {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	node, err := m.resolve(name, false)
+	if err != nil {
+		return wrap(err)
+	}
+	if node == m.root {
+		return wrap(syscall.EBUSY)
+	}
+	if node.isDir() && len(node.children) > 0 {
+		return wrap(syscall.ENOTEMPTY)
+	}
+	m.detach(node)
+	return nil
+}
+
+func (m *MemBackend) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, false)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return &os.PathError{Op: "RemoveAll", Path: name, Err: err}
+	}
+	if node == m.root {
+		return &os.PathError{Op: "RemoveAll", Path: name, Err: syscall.EBUSY}
+	}
+	m.detach(node)
+	return nil
+}
+
+func (m *MemBackend) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wrap := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	// like on Linux, both parents are looked up before the source
+	if _, _, err := m.parent(oldpath); err != nil && err != os.ErrExist {
+		return wrap(err)
+	}
+	dir, base, err := m.parent(newpath)
+	if err != nil {
+		return wrap(err)
+	}
+
+	src, err := m.resolve(oldpath, false)
+	if err != nil {
+		return wrap(err)
+	}
+	if src == m.root {
+		return wrap(syscall.EBUSY)
+	}
+	existing, exists := dir.children[memKey(base)]
+	if exists && existing.isDir() && (existing != src || existing.name == base) {
+		// like os.Rename, never replace a directory, unless it's a
+		// case-only rename of the source itself
+		return wrap(syscall.EEXIST)
+	}
+
+	for d := dir; d != nil; d = d.parent {
+		if d == src {
+			// can't move a directory inside of itself
+			return wrap(syscall.EINVAL)
+		}
+	}
+
+	if exists && existing != src {
+		if src.isDir() {
+			return wrap(syscall.ENOTDIR)
+		}
+		m.detach(existing)
+	}
+
+	// note: for case-only renames, `existing` is `src`
+	m.detach(src)
+	src.name = base
+	src.parent = dir
+	dir.children[memKey(base)] = src
+	return nil
+}
+
+func (m *MemBackend) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wrap := func(err error) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+
+	dir, base, err := m.parent(newname)
+	if err != nil {
+		return wrap(err)
+	}
+	if _, ok := dir.children[memKey(base)]; ok {
+		return wrap(os.ErrExist)
+	}
+	node := m.create(dir, base, os.ModeSymlink|0o777)
+	node.target = oldname
+	return nil
+}
+
+func (m *MemBackend) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, false)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	if !node.isSymlink() {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return node.target, nil
+}
+
+func (m *MemBackend) Truncate(name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wrap := func(err error) error {
+		return &os.PathError{Op: "truncate", Path: name, Err: err}
+	}
+
+	node, err := m.resolve(name, true)
+	if err != nil {
+		return wrap(err)
+	}
+	if node.isDir() {
+		return wrap(syscall.EISDIR)
+	}
+	if size < 0 {
+		return wrap(syscall.EINVAL)
+	}
+	node.resize(size)
+	return nil
+}
+
+func (m *MemBackend) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, true)
+	if err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	const chmodBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	node.mode = node.mode&^chmodBits | mode&chmodBits
+	return nil
+}
+
+// Chtimes only sets the modification time, access times aren't
+// tracked. Like os.Chtimes, a zero mtime leaves it unchanged.
+func (m *MemBackend) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, true)
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	if !mtime.IsZero() {
+		node.modTime = mtime
+	}
+	return nil
+}
+
+func (m *MemBackend) TrueBaseName(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(name, false)
+	if err != nil {
+		return ""
+	}
+	return node.name
+}
+
+func (m *MemBackend) FileTrueBaseName(f File) string {
+	mf, ok := f.(*memFile)
+	if !ok || mf.m != m {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return mf.node.name
+}
+
+func (m *MemBackend) IsCaseInsensitive() bool {
+	return true
+}
+
+func (n *memNode) resize(size int64) {
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size]
+	} else {
+		n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+	}
+	n.modTime = time.Now()
+}
+
+type memFile struct {
+	m      *MemBackend
+	node   *memNode
+	name   string
+	flag   int
+	offset int64
+	closed bool
+
+	// directory listing, snapshotted on the first Readdir call
+	dirents []*memNode
+	listed  bool
+}
+
+var _ File = (*memFile)(nil)
+
+func (f *memFile) readable() bool {
+	return f.flag&os.O_WRONLY == 0
+}
+
+func (f *memFile) writable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+func (f *memFile) check(op string, write bool) error {
+	wrap := func(err error) error {
+		return &os.PathError{Op: op, Path: f.name, Err: err}
+	}
+	if f.closed {
+		return wrap(os.ErrClosed)
+	}
+	if write && !f.writable() {
+		return wrap(syscall.EBADF)
+	}
+	if !write && !f.readable() {
+		return wrap(syscall.EBADF)
+	}
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	n, err := f.readAt("read", p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(op string, p []byte, off int64) (int, error) {
+	if err := f.check(op, false); err != nil {
+		return 0, err
+	}
+	if f.node.isDir() {
+		return 0, &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+	if off >= int64(len(f.node.data)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(p, f.node.data[off:]), nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	n, err := f.writeAt("write", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	return f.writeAt("write", p, off)
+}
+
+func (f *memFile) writeAt(op string, p []byte, off int64) (int, error) {
+	if err := f.check(op, true); err != nil {
+		return 0, err
+	}
+	end := off + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		f.node.resize(end)
+	}
+	copy(f.node.data[off:], p)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if f.closed {
+		return nil, &os.PathError{Op: "stat", Path: f.name, Err: os.ErrClosed}
+	}
+	return f.node.info(filepath.Base(f.name)), nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.node.resize(size)
+	return nil
+}
+
+// readdir returns up to n entries (all remaining ones if n <= 0),
+// following the conventions of (*os.File).Readdir
+func (f *memFile) readdir(n int) ([]os.FileInfo, error) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	if err := f.check("readdirent", false); err != nil {
+		return nil, err
+	}
+	if !f.node.isDir() {
+		return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: syscall.ENOTDIR}
+	}
+	if !f.listed {
+		f.dirents = f.node.sortedChildren()
+		f.listed = true
+	}
+
+	var nodes []*memNode
+	if n <= 0 {
+		nodes = f.dirents
+		f.dirents = nil
+	} else {
+		if len(f.dirents) == 0 {
+			return nil, io.EOF
+		}
+		n = min(n, len(f.dirents))
+		nodes = f.dirents[:n]
+		f.dirents = f.dirents[n:]
+	}
+
+	infos := make([]os.FileInfo, 0, len(nodes))
+	for _, node := range nodes {
+		infos = append(infos, node.info(node.name))
+	}
+	return infos, nil
+}
+
+func (f *memFile) Readdir(n int) ([]os.FileInfo, error) {
+	return f.readdir(n)
+}
+
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.readdir(n)
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names, err
+}
+
+func (f *memFile) ReadDir(n int) ([]os.DirEntry, error) {
+	infos, err := f.readdir(n)
+	entries := make([]os.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, err
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+var _ os.FileInfo = (*memFileInfo)(nil)
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() any           { return nil }
@@ -0,0 +1,178 @@
+package screw_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func memPath(parts ...string) string {
+	return filepath.Join(append([]string{string(filepath.Separator), "mem"}, parts...)...)
+}
+
+func Test_MemBackend_CasePreserving(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("Apricot"), 0o755))
+
+	f, err := mem.OpenFile(memPath("APRICOT", "Seed"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	must(err)
+	_, err = f.Write([]byte("hello"))
+	must(err)
+	must(f.Close())
+
+	assert.EqualValues("Apricot", mem.TrueBaseName(memPath("apricot")))
+	assert.EqualValues("Seed", mem.TrueBaseName(memPath("apricot", "SEED")))
+	assert.EqualValues("", mem.TrueBaseName(memPath("apricot", "pit")))
+
+	stats, err := mem.Stat(memPath("apricot", "seed"))
+	must(err)
+	assert.EqualValues("seed", stats.Name(), "stat returns the name it was given")
+	assert.EqualValues(5, stats.Size())
+
+	f, err = mem.OpenFile(memPath("apricot"), os.O_RDONLY, 0)
+	must(err)
+	names, err := f.Readdirnames(-1)
+	must(err)
+	must(f.Close())
+	assert.EqualValues([]string{"Seed"}, names)
+
+	_, err = mem.OpenFile(memPath("APRICOT", "seed"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	assert.True(os.IsExist(err))
+}
+
+func Test_MemBackend_Rename(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("dir", "sub"), 0o755))
+
+	// case-only rename
+	must(mem.Rename(memPath("dir"), memPath("DIR")))
+	assert.EqualValues("DIR", mem.TrueBaseName(memPath("dir")))
+
+	// can't move a directory into itself
+	err := mem.Rename(memPath("dir"), memPath("dir", "sub", "dir"))
+	assert.Error(err)
+
+	// can't replace a non-empty directory
+	must(mem.Mkdir(memPath("other"), 0o755))
+	err = mem.Rename(memPath("other"), memPath("dir"))
+	assert.Error(err)
+
+	// renaming onto a case variant replaces it
+	writeMem(mem, memPath("banana"), "banana")
+	writeMem(mem, memPath("apricot"), "apricot")
+	must(mem.Rename(memPath("banana"), memPath("APRICOT")))
+	assert.EqualValues("APRICOT", mem.TrueBaseName(memPath("apricot")))
+	assert.EqualValues("banana", readMem(mem, memPath("apricot")))
+
+	_, err = mem.Lstat(memPath("banana"))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_MemBackend_RenameReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("dir"), 0o755))
+	must(mem.MkdirAll(memPath("empty"), 0o755))
+	writeMem(mem, memPath("file"), "file")
+
+	// like os.Rename, directories are never replaced, even empty ones
+	err := mem.Rename(memPath("dir"), memPath("EMPTY"))
+	assert.True(errors.Is(err, syscall.EEXIST), "got %+v", err)
+	err = mem.Rename(memPath("file"), memPath("empty"))
+	assert.True(errors.Is(err, syscall.EEXIST), "got %+v", err)
+	assert.EqualValues("file", readMem(mem, memPath("file")))
+
+	// a directory can't replace a file
+	err = mem.Rename(memPath("dir"), memPath("FILE"))
+	assert.True(errors.Is(err, syscall.ENOTDIR), "got %+v", err)
+	assert.EqualValues("file", readMem(mem, memPath("file")))
+
+	// parents are looked up before the source, like on Linux
+	err = mem.Rename(memPath("missing"), memPath("file", "missing"))
+	assert.True(errors.Is(err, syscall.ENOTDIR), "got %+v", err)
+
+	// a case-only rename of a directory is fine
+	must(mem.Rename(memPath("dir"), memPath("Dir")))
+	assert.EqualValues("Dir", mem.TrueBaseName(memPath("dir")))
+}
+
+func Test_MemBackend_Symlinks(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("real", "dir"), 0o755))
+	writeMem(mem, memPath("real", "dir", "file"), "contents")
+	must(mem.Symlink(filepath.Join("real", "dir"), memPath("link")))
+
+	assert.EqualValues("contents", readMem(mem, memPath("LINK", "file")))
+
+	stats, err := mem.Lstat(memPath("link"))
+	must(err)
+	assert.True(stats.Mode()&os.ModeSymlink != 0)
+
+	stats, err = mem.Stat(memPath("link"))
+	must(err)
+	assert.True(stats.IsDir())
+
+	target, err := mem.Readlink(memPath("link"))
+	must(err)
+	assert.EqualValues(filepath.Join("real", "dir"), target)
+
+	_, err = mem.Readlink(memPath("real"))
+	assert.Error(err)
+}
+
+func Test_MemBackend_Readdir(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	for _, name := range []string{"c", "a", "B"} {
+		writeMem(mem, memPath(name), name)
+	}
+
+	f, err := mem.OpenFile(memPath(), os.O_RDONLY, 0)
+	must(err)
+	defer f.Close()
+
+	var names []string
+	for {
+		infos, err := f.Readdir(2)
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		if err == io.EOF {
+			break
+		}
+		must(err)
+	}
+	assert.EqualValues([]string{"B", "a", "c"}, names)
+}
+
+func writeMem(mem *screw.MemBackend, name string, contents string) {
+	f, err := mem.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	must(err)
+	_, err = f.Write([]byte(contents))
+	must(err)
+	must(f.Close())
+}
+
+func readMem(mem *screw.MemBackend, name string) string {
+	f, err := mem.OpenFile(name, os.O_RDONLY, 0)
+	must(err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	must(err)
+	return string(data)
+}
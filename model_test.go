@@ -0,0 +1,485 @@
+package screw_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/screw"
+)
+
+// The model tests run random sequences of operations against screw and
+// against modelFS, a reference implementation of case-sensible semantics
+// that knows nothing about screw, and report the shortest sequence they
+// can find for which the two disagree.
+
+type modelOpKind int
+
+const (
+	modelCreate modelOpKind = iota
+	modelMkdir
+	modelRename
+	modelRemove
+	modelRemoveAll
+	modelStat
+	modelOpKinds
+)
+
+func (k modelOpKind) String() string {
+	return [...]string{"Create", "Mkdir", "Rename", "Remove", "RemoveAll", "Stat"}[k]
+}
+
+type modelOp struct {
+	kind modelOpKind
+	path string
+	// only for Rename
+	dest string
+}
+
+func (op modelOp) String() string {
+	if op.kind == modelRename {
+		return fmt.Sprintf("%s(%q, %q)", op.kind, op.path, op.dest)
+	}
+	return fmt.Sprintf("%s(%q)", op.kind, op.path)
+}
+
+// modelPaths are the paths operations pick from: few enough names that
+// sequences keep running into existing entries and their case variants.
+var modelPaths = func() []string {
+	names := []string{"a", "A", "b", "B"}
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, name)
+	}
+	for _, parent := range names {
+		for _, name := range names {
+			paths = append(paths, parent+"/"+name)
+		}
+	}
+	return paths
+}()
+
+// decodeModelOps turns fuzzer input into operations, 3 bytes each
+func decodeModelOps(data []byte) []modelOp {
+	var ops []modelOp
+	for len(data) >= 3 {
+		ops = append(ops, modelOp{
+			kind: modelOpKind(int(data[0]) % int(modelOpKinds)),
+			path: modelPaths[int(data[1])%len(modelPaths)],
+			dest: modelPaths[int(data[2])%len(modelPaths)],
+		})
+		data = data[3:]
+	}
+	return ops
+}
+
+func randomModelOps(rng *rand.Rand, n int) []modelOp {
+	data := make([]byte, n*3)
+	rng.Read(data)
+	return decodeModelOps(data)
+}
+
+// outcome classifies errors, so that screw and the model can
+// be compared without caring about error messages.
+type outcome string
+
+const (
+	outcomeOK       outcome = "ok"
+	outcomeNotExist outcome = "not-exist"
+	outcomeExist    outcome = "exist"
+	outcomeConflict outcome = "case-conflict"
+	outcomeNotDir   outcome = "not-dir"
+	outcomeIsDir    outcome = "is-dir"
+	outcomeNotEmpty outcome = "not-empty"
+	outcomeInvalid  outcome = "invalid"
+)
+
+func classify(err error) outcome {
+	switch {
+	case err == nil:
+		return outcomeOK
+	case screw.IsCaseConflict(err):
+		return outcomeConflict
+	case errors.Is(err, syscall.ENOTEMPTY):
+		// must come first, it's also fs.ErrExist
+		return outcomeNotEmpty
+	case errors.Is(err, fs.ErrNotExist):
+		return outcomeNotExist
+	case errors.Is(err, fs.ErrExist):
+		return outcomeExist
+	case errors.Is(err, syscall.ENOTDIR):
+		return outcomeNotDir
+	case errors.Is(err, syscall.EISDIR):
+		return outcomeIsDir
+	case errors.Is(err, syscall.EINVAL):
+		return outcomeInvalid
+	default:
+		return outcome(fmt.Sprintf("other: %v", err))
+	}
+}
+
+type modelNode struct {
+	name     string
+	dir      bool
+	parent   *modelNode
+	children map[string]*modelNode
+}
+
+// modelFS is the reference: a tree of names, looked up exactly on
+// case-sensitive filesystems, and by folding case on case-insensitive
+// ones, where screw must act as if they were looked up exactly.
+type modelFS struct {
+	insensitive bool
+	root        *modelNode
+}
+
+func newModelFS(insensitive bool) *modelFS {
+	return &modelFS{
+		insensitive: insensitive,
+		root:        &modelNode{dir: true, children: make(map[string]*modelNode)},
+	}
+}
+
+// child returns the entry of `dir` the filesystem finds for `name`,
+// which may be a case variant on case-insensitive filesystems.
+func (m *modelFS) child(dir *modelNode, name string) *modelNode {
+	if c, ok := dir.children[name]; ok {
+		return c
+	}
+	if m.insensitive {
+		for childName, c := range dir.children {
+			if strings.EqualFold(childName, name) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// parent resolves the directory containing `path`
+func (m *modelFS) parent(path string) (*modelNode, string, outcome) {
+	parts := strings.Split(path, "/")
+	dir := m.root
+	for _, part := range parts[:len(parts)-1] {
+		c := m.child(dir, part)
+		if c == nil {
+			return nil, "", outcomeNotExist
+		}
+		if !c.dir {
+			return nil, "", outcomeNotDir
+		}
+		dir = c
+	}
+	return dir, parts[len(parts)-1], outcomeOK
+}
+
+func (m *modelFS) add(dir *modelNode, name string, isDir bool) {
+	node := &modelNode{name: name, dir: isDir, parent: dir}
+	if isDir {
+		node.children = make(map[string]*modelNode)
+	}
+	dir.children[name] = node
+}
+
+func (m *modelFS) apply(op modelOp) outcome {
+	dir, base, res := m.parent(op.path)
+	if res != outcomeOK {
+		if op.kind == modelRemoveAll && res == outcomeNotExist {
+			return outcomeOK
+		}
+		return res
+	}
+	found := m.child(dir, base)
+	exact := found != nil && found.name == base
+
+	switch op.kind {
+	case modelStat:
+		if !exact {
+			return outcomeNotExist
+		}
+		return outcomeOK
+
+	case modelCreate:
+		switch {
+		case found == nil:
+			m.add(dir, base, false)
+			return outcomeOK
+		case !exact:
+			return outcomeConflict
+		case found.dir:
+			return outcomeIsDir
+		default:
+			return outcomeOK
+		}
+
+	case modelMkdir:
+		switch {
+		case found == nil:
+			m.add(dir, base, true)
+			return outcomeOK
+		case !exact:
+			return outcomeConflict
+		default:
+			return outcomeExist
+		}
+
+	case modelRemove:
+		switch {
+		case !exact:
+			return outcomeNotExist
+		case found.dir && len(found.children) > 0:
+			return outcomeNotEmpty
+		default:
+			delete(dir.children, found.name)
+			return outcomeOK
+		}
+
+	case modelRemoveAll:
+		if exact {
+			delete(dir.children, found.name)
+		}
+		return outcomeOK
+
+	case modelRename:
+		// Rename doesn't check case, it has the filesystem's semantics,
+		// except that case-only renames must change the case. Both
+		// parents are looked up before the source.
+		destDir, destBase, res := m.parent(op.dest)
+		if res != outcomeOK {
+			return res
+		}
+		src := found
+		if src == nil {
+			return outcomeNotExist
+		}
+		// os.Rename never replaces directories, not even with
+		// themselves, unless it's a case-only rename.
+		existing := m.child(destDir, destBase)
+		if existing != nil && existing.dir && (existing != src || existing.name == destBase) {
+			return outcomeExist
+		}
+		for d := destDir; d != nil; d = d.parent {
+			if d == src {
+				return outcomeInvalid
+			}
+		}
+		if existing != nil && existing != src {
+			if src.dir {
+				return outcomeNotDir
+			}
+			delete(destDir.children, existing.name)
+		}
+		delete(src.parent.children, src.name)
+		src.name = destBase
+		src.parent = destDir
+		destDir.children[destBase] = src
+		return outcomeOK
+	}
+	panic("unknown op")
+}
+
+// list returns every entry, as "path/" for directories
+func (m *modelFS) list() []string {
+	var entries []string
+	var walk func(node *modelNode, prefix string)
+	walk = func(node *modelNode, prefix string) {
+		for name, c := range node.children {
+			if c.dir {
+				entries = append(entries, prefix+name+"/")
+				walk(c, prefix+name+"/")
+			} else {
+				entries = append(entries, prefix+name)
+			}
+		}
+	}
+	walk(m.root, "")
+	sort.Strings(entries)
+	return entries
+}
+
+// modelEnv is what the sequences run against
+type modelEnv struct {
+	name string
+	// returns a case-sensible FS, the raw backend and an empty directory
+	setup func(t *testing.T) (*screw.FS, screw.Backend, string, bool)
+}
+
+func listModelEnvs() []modelEnv {
+	envs := []modelEnv{
+		{
+			name: "mem",
+			setup: func(t *testing.T) (*screw.FS, screw.Backend, string, bool) {
+				mem := screw.NewMemBackend()
+				must(mem.MkdirAll(memPath(), 0o755))
+				return screw.New(screw.WithBackend(mem)), mem, memPath(), true
+			},
+		},
+	}
+	if runtime.GOOS == "linux" {
+		envs = append(envs, modelEnv{
+			name: "os",
+			setup: func(t *testing.T) (*screw.FS, screw.Backend, string, bool) {
+				dir := t.TempDir()
+				insensitive, err := screw.IsCaseInsensitiveDir(dir)
+				must(err)
+				return screw.New(), screw.OSBackend{}, dir, insensitive
+			},
+		})
+	}
+	return envs
+}
+
+func applyScrew(fsys *screw.FS, root string, op modelOp) outcome {
+	full := func(path string) string {
+		return filepath.Join(root, filepath.FromSlash(path))
+	}
+
+	var err error
+	switch op.kind {
+	case modelCreate:
+		var f screw.File
+		f, err = fsys.Create(full(op.path))
+		if err == nil {
+			f.Close()
+		}
+	case modelMkdir:
+		err = fsys.Mkdir(full(op.path), 0o755)
+	case modelRename:
+		err = fsys.Rename(full(op.path), full(op.dest))
+	case modelRemove:
+		err = fsys.Remove(full(op.path))
+	case modelRemoveAll:
+		err = fsys.RemoveAll(full(op.path))
+	case modelStat:
+		_, err = fsys.Stat(full(op.path))
+	}
+	return classify(err)
+}
+
+// listBackend returns every entry below `root`, like modelFS.list
+func listBackend(b screw.Backend, root string) []string {
+	var entries []string
+	var walk func(dir string, prefix string)
+	walk = func(dir string, prefix string) {
+		f, err := b.OpenFile(dir, os.O_RDONLY, 0)
+		must(err)
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		must(err)
+		for _, name := range names {
+			stats, err := b.Lstat(filepath.Join(dir, name))
+			must(err)
+			if stats.IsDir() {
+				entries = append(entries, prefix+name+"/")
+				walk(filepath.Join(dir, name), prefix+name+"/")
+			} else {
+				entries = append(entries, prefix+name)
+			}
+		}
+	}
+	walk(root, "")
+	sort.Strings(entries)
+	return entries
+}
+
+// runModel runs `ops` against both screw and the model, and returns a
+// description of the first divergence, or "" if there is none.
+func runModel(t *testing.T, env modelEnv, ops []modelOp) string {
+	fsys, raw, root, insensitive := env.setup(t)
+	model := newModelFS(insensitive)
+
+	for i, op := range ops {
+		expected := model.apply(op)
+		actual := applyScrew(fsys, root, op)
+		if expected != actual {
+			return fmt.Sprintf("op %d, %s: model says %s, screw says %s", i, op, expected, actual)
+		}
+
+		expectedTree := model.list()
+		actualTree := listBackend(raw, root)
+		if strings.Join(expectedTree, "\n") != strings.Join(actualTree, "\n") {
+			return fmt.Sprintf("op %d, %s: model has %q, screw has %q", i, op, expectedTree, actualTree)
+		}
+	}
+	return ""
+}
+
+// shrinkModel removes operations from a diverging sequence for as long
+// as it keeps diverging, so that every remaining operation is needed.
+// It tries removing large chunks first, then smaller ones.
+func shrinkModel(t *testing.T, env modelEnv, ops []modelOp) ([]modelOp, string) {
+	divergence := runModel(t, env, ops)
+	for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+		for i := 0; i+chunk <= len(ops); {
+			candidate := append(append([]modelOp(nil), ops[:i]...), ops[i+chunk:]...)
+			if d := runModel(t, env, candidate); d != "" {
+				ops, divergence = candidate, d
+				continue
+			}
+			i++
+		}
+	}
+	return ops, divergence
+}
+
+func reportModel(t *testing.T, env modelEnv, ops []modelOp, divergence string) {
+	t.Helper()
+
+	var lines []string
+	for _, op := range ops {
+		lines = append(lines, "  "+op.String())
+	}
+	t.Fatalf("screw diverges from the model on %s after:\n%s\n%s", env.name, strings.Join(lines, "\n"), divergence)
+}
+
+func Test_Model(t *testing.T) {
+	runs := 200
+	if testing.Short() {
+		runs = 20
+	}
+
+	for _, env := range listModelEnvs() {
+		t.Run(env.name, func(t *testing.T) {
+			for seed := int64(0); seed < int64(runs); seed++ {
+				ops := randomModelOps(rand.New(rand.NewSource(seed)), 30)
+				if runModel(t, env, ops) != "" {
+					shrunk, divergence := shrinkModel(t, env, ops)
+					reportModel(t, env, shrunk, divergence)
+				}
+			}
+		})
+	}
+}
+
+// longer sequences don't find more, they just make minimizing slow
+const maxFuzzModelOps = 64
+
+// Fuzz_Model runs fuzzer-generated sequences. Minimizing new inputs
+// dominates otherwise, so run it with something like:
+//
+//	go test -run '^$' -fuzz Fuzz_Model -fuzzminimizetime 100x
+func Fuzz_Model(f *testing.F) {
+	f.Add([]byte{byte(modelMkdir), 0, 0, byte(modelCreate), 1, 0, byte(modelRename), 0, 1})
+	f.Add([]byte{byte(modelMkdir), 0, 0, byte(modelCreate), 4, 0, byte(modelRemoveAll), 1, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := decodeModelOps(data)
+		if len(ops) > maxFuzzModelOps {
+			ops = ops[:maxFuzzModelOps]
+		}
+		// no shrinking here: the fuzzer minimizes failing inputs itself
+		for _, env := range listModelEnvs() {
+			if divergence := runModel(t, env, ops); divergence != "" {
+				reportModel(t, env, ops, divergence)
+			}
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package screw
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizationPolicy controls how names that only differ by Unicode
+// normalization are treated, like "é" written as a single code point
+// (NFC, what most software produces) and "e" followed by a combining
+// acute accent (NFD, what HFS+ stores).
+//
+// macOS filesystems are normalization-insensitive, so both forms name
+// the same entry there. Most other filesystems, including NTFS and
+// ext4, treat them as different names.
+type NormalizationPolicy int
+
+const (
+	// NormalizationSame is the default: names that only differ by
+	// normalization are the same name. Case checks don't report them,
+	// and collision detection groups them, since they'd end up as
+	// a single entry on macOS.
+	NormalizationSame NormalizationPolicy = iota
+	// NormalizationConflict is like NormalizationSame, except case
+	// checks report a normalization difference like a case difference,
+	// so that paths have to match the on-disk name exactly.
+	NormalizationConflict
+	// NormalizationDistinct treats names that only differ by
+	// normalization as different names: collision detection doesn't
+	// group them, CaseLenient doesn't resolve one to the other, and
+	// case checks report them, like with NormalizationConflict.
+	NormalizationDistinct
+)
+
+func (np NormalizationPolicy) String() string {
+	switch np {
+	case NormalizationSame:
+		return "same"
+	case NormalizationConflict:
+		return "conflict"
+	case NormalizationDistinct:
+		return "distinct"
+	default:
+		return fmt.Sprintf("NormalizationPolicy(%d)", int(np))
+	}
+}
+
+// WithNormalization sets how names that only differ by Unicode
+// normalization are treated, see NormalizationPolicy
+func WithNormalization(policy NormalizationPolicy) Option {
+	return func(fs *FS) {
+		fs.normalization = policy
+	}
+}
+
+// sameName returns true if `actual`, a name as found on disk, matches
+// `requested` as far as case checks are concerned.
+func (fs *FS) sameName(actual string, requested string) bool {
+	if actual == requested {
+		return true
+	}
+	return fs.normalization == NormalizationSame && norm.NFC.String(actual) == norm.NFC.String(requested)
+}
+
+// portableKey is nameKey for content that has to work everywhere, so
+// that the answer doesn't depend on the platform screw runs on: names
+// share a key if they'd collide under any FoldFlavor. That's APFS's
+// full case folding, which covers NTFS's simple one, minus the
+// invisible characters HFS+ ignores.
+func (fs *FS) portableKey(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if hfsIgnorable(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if fs.normalization == NormalizationDistinct {
+		return foldCase(name, FoldAPFS)
+	}
+	return FoldName(name, FoldAPFS)
+}
+
+// nameKey returns the form of `name` that all the names it would
+// collide with share, on a case-insensitive filesystem.
+func (fs *FS) nameKey(name string) string {
+	if fs.normalization == NormalizationDistinct {
+		return foldCase(name, platformFoldFlavor)
+	}
+	return norm.NFD.String(foldName(norm.NFD.String(name)))
+}
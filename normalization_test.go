@@ -0,0 +1,124 @@
+package screw_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// "café" with a precomposed é, and with a combining accent
+	cafeNFC = "caf\u00e9"
+	cafeNFD = "cafe\u0301"
+)
+
+func Test_Normalization_CaseChecks(t *testing.T) {
+	// like HFS+, MemBackend is normalization-insensitive
+	// and keeps names as they were created.
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(cafeNFD), 0o755))
+	writeMem(mem, memPath(cafeNFD, "menu.txt"), "croissant")
+
+	for _, policy := range []screw.NormalizationPolicy{
+		screw.NormalizationSame,
+		screw.NormalizationConflict,
+		screw.NormalizationDistinct,
+	} {
+		t.Run(policy.String(), func(t *testing.T) {
+			assert := assert.New(t)
+
+			var violations []screw.CaseViolation
+			fs := screw.New(
+				screw.WithBackend(mem),
+				screw.WithNormalization(policy),
+				screw.WithCaseViolationHandler(func(v screw.CaseViolation) {
+					violations = append(violations, v)
+				}),
+			)
+			strict := fs.With(screw.WithCaseMode(screw.CaseStrict))
+
+			assert.False(fs.IsWrongCase(memPath(cafeNFD)))
+			assert.True(fs.IsWrongCase(memPath("CAF\u00c9")))
+
+			same := policy == screw.NormalizationSame
+			assert.Equal(!same, fs.IsWrongCase(memPath(cafeNFC)))
+
+			_, err := fs.Stat(memPath(cafeNFC))
+			_, strictErr := strict.ReadFile(memPath(cafeNFC, "menu.txt"))
+			if same {
+				assert.NoError(err)
+				assert.NoError(strictErr)
+				assert.Empty(violations)
+			} else {
+				assert.True(os.IsNotExist(err))
+				assert.True(os.IsNotExist(strictErr))
+				assert.Len(violations, 2)
+			}
+
+			// case differences are reported either way
+			_, err = fs.Stat(memPath("CAFE\u0301"))
+			assert.True(os.IsNotExist(err))
+		})
+	}
+}
+
+func Test_Normalization_Collisions(t *testing.T) {
+	assert := assert.New(t)
+
+	paths := []string{cafeNFC, cafeNFD, "CAF\u00c9", "tea"}
+
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"CAF\u00c9", cafeNFD, cafeNFC}},
+	}, screw.FindPathCaseCollisions(paths))
+
+	conflict := screw.New(screw.WithNormalization(screw.NormalizationConflict))
+	assert.EqualValues(screw.FindPathCaseCollisions(paths), conflict.FindPathCaseCollisions(paths))
+
+	distinct := screw.New(screw.WithNormalization(screw.NormalizationDistinct))
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{"CAF\u00c9", cafeNFC}},
+	}, distinct.FindPathCaseCollisions(paths))
+
+	// duplicates that are only normalization differences, as found
+	// in archives made on Linux
+	tmpDir := t.TempDir()
+	must(os.WriteFile(filepath.Join(tmpDir, cafeNFC), nil, 0o644))
+	if _, err := os.Lstat(filepath.Join(tmpDir, cafeNFD)); err == nil {
+		t.Skip("needs a normalization-sensitive temporary directory")
+	}
+	must(os.WriteFile(filepath.Join(tmpDir, cafeNFD), nil, 0o644))
+
+	collisions, err := screw.FindCaseCollisions(tmpDir)
+	assert.NoError(err)
+	assert.EqualValues([]screw.CaseCollision{
+		{Paths: []string{cafeNFD, cafeNFC}},
+	}, collisions)
+
+	collisions, err = distinct.FindCaseCollisions(tmpDir)
+	assert.NoError(err)
+	assert.Empty(collisions)
+}
+
+func Test_Normalization_Lenient(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := caseSensitiveTempDir(t)
+	must(os.WriteFile(filepath.Join(tmpDir, cafeNFD), []byte("croissant"), 0o644))
+	if _, err := os.Lstat(filepath.Join(tmpDir, cafeNFC)); err == nil {
+		t.Skip("needs a normalization-sensitive temporary directory")
+	}
+
+	lenient := screw.New(screw.WithCaseMode(screw.CaseLenient), screw.WithRoot(tmpDir))
+	data, err := lenient.ReadFile(filepath.Join(tmpDir, "CAF\u00c9"))
+	assert.NoError(err)
+	assert.Equal("croissant", string(data))
+
+	distinct := lenient.With(screw.WithNormalization(screw.NormalizationDistinct))
+	_, err = distinct.ReadFile(filepath.Join(tmpDir, "CAF\u00c9"))
+	assert.True(os.IsNotExist(err))
+	_, err = distinct.ReadFile(filepath.Join(tmpDir, "CAFE\u0301"))
+	assert.NoError(err)
+}
@@ -0,0 +1,211 @@
+package screw
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// PortabilityReason is why a name can't be used on Windows or macOS
+type PortabilityReason int
+
+const (
+	// PortabilityReservedName is a Windows device name: CON, PRN, AUX,
+	// NUL, COM0 to COM9 and LPT0 to LPT9 (including COM¹, COM², COM³,
+	// LPT¹, LPT² and LPT³), in any case, with or without an extension,
+	// like "aux" or "CON.txt".
+	PortabilityReservedName PortabilityReason = iota
+	// PortabilityInvalidChar is one of < > : " / \ | ? *
+	PortabilityInvalidChar
+	// PortabilityControlChar is a character between U+0000 and U+001F
+	PortabilityControlChar
+	// PortabilityTrailingDot is a name ending with ".", which Windows
+	// silently strips.
+	PortabilityTrailingDot
+	// PortabilityTrailingSpace is a name ending with " ", which Windows
+	// silently strips.
+	PortabilityTrailingSpace
+	// PortabilityInvalidUTF8 is a name that isn't valid UTF-8, and so
+	// can't be converted to the UTF-16 of NTFS, or stored on APFS.
+	PortabilityInvalidUTF8
+	// PortabilityNameTooLong is a name longer than 255 UTF-16 code
+	// units (NTFS, HFS+) or 255 bytes of UTF-8 (APFS).
+	PortabilityNameTooLong
+	// PortabilityPathTooLong is a path longer than MAX_PATH, ie. 259
+	// UTF-16 code units, which most Windows software can't open.
+	PortabilityPathTooLong
+)
+
+func (pr PortabilityReason) String() string {
+	switch pr {
+	case PortabilityReservedName:
+		return "reserved device name"
+	case PortabilityInvalidChar:
+		return "invalid character"
+	case PortabilityControlChar:
+		return "control character"
+	case PortabilityTrailingDot:
+		return "trailing dot"
+	case PortabilityTrailingSpace:
+		return "trailing space"
+	case PortabilityInvalidUTF8:
+		return "invalid UTF-8"
+	case PortabilityNameTooLong:
+		return "name too long"
+	case PortabilityPathTooLong:
+		return "path too long"
+	default:
+		return fmt.Sprintf("PortabilityReason(%d)", int(pr))
+	}
+}
+
+const (
+	// longest name NTFS, HFS+ and APFS all accept
+	maxPortableName = 255
+	// MAX_PATH, minus the terminating NUL
+	maxPortablePath = 259
+)
+
+// PortabilityProblem is a single reason a name isn't portable
+type PortabilityProblem struct {
+	Reason PortabilityReason
+	// Name is the offending path component, or the whole path
+	// for PortabilityPathTooLong
+	Name string
+}
+
+func (pp PortabilityProblem) String() string {
+	return fmt.Sprintf("%q: %s", pp.Name, pp.Reason)
+}
+
+// ValidatePortableName returns a *PortabilityError listing every
+// reason `name`, a single path component, can't be created on Windows
+// or macOS, or nil if it can. "." and ".." are accepted.
+func ValidatePortableName(name string) error {
+	problems := portabilityProblems(name, nil)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &PortabilityError{Path: name, Problems: problems}
+}
+
+// ValidatePortablePath is ValidatePortableName for every component of
+// `path`, which also checks that the whole path fits in MAX_PATH. For
+// relative paths, that only accounts for `path` itself, not for the
+// directory it ends up in.
+//
+// Both forward slashes and the OS separator separate components.
+func ValidatePortablePath(path string) error {
+	var problems []PortabilityProblem
+	for _, part := range strings.Split(filepath.ToSlash(path[len(filepath.VolumeName(path)):]), "/") {
+		problems = portabilityProblems(part, problems)
+	}
+	if utf16Len(path) > maxPortablePath {
+		problems = append(problems, PortabilityProblem{Reason: PortabilityPathTooLong, Name: path})
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &PortabilityError{Path: path, Problems: problems}
+}
+
+// portabilityProblems appends the problems of `name` to `problems`
+func portabilityProblems(name string, problems []PortabilityProblem) []PortabilityProblem {
+	if name == "" || name == "." || name == ".." {
+		return problems
+	}
+	add := func(reason PortabilityReason) {
+		problems = append(problems, PortabilityProblem{Reason: reason, Name: name})
+	}
+
+	if isReservedName(name) {
+		add(PortabilityReservedName)
+	}
+	if strings.ContainsAny(name, `<>:"/\|?*`) {
+		add(PortabilityInvalidChar)
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return r < 0x20 }) != -1 {
+		add(PortabilityControlChar)
+	}
+	if strings.HasSuffix(name, ".") {
+		add(PortabilityTrailingDot)
+	}
+	if strings.HasSuffix(name, " ") {
+		add(PortabilityTrailingSpace)
+	}
+	if !utf8.ValidString(name) {
+		add(PortabilityInvalidUTF8)
+	}
+	if len(name) > maxPortableName || utf16Len(name) > maxPortableName {
+		add(PortabilityNameTooLong)
+	}
+	return problems
+}
+
+// isReservedName returns true if `name` refers to a device on Windows.
+// Only what comes before the first dot counts, minus trailing spaces.
+func isReservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	stem = strings.ToUpper(strings.TrimRight(stem, " "))
+
+	switch stem {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(stem) > 3 && (stem[:3] == "COM" || stem[:3] == "LPT") {
+		switch stem[3:] {
+		case "0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "\u00b9", "\u00b2", "\u00b3":
+			return true
+		}
+	}
+	return false
+}
+
+// utf16Len returns the number of UTF-16 code units `s` converts to
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// WithPortableNames makes Create, OpenFile and WriteFile (with
+// O_CREATE), Mkdir, MkdirAll, Symlink and Rename (the new path) fail
+// with a *PortabilityError when given a path that couldn't be created
+// on Windows or macOS. Only components below the root (see WithRoot)
+// are validated, so that an install directory can be validated
+// independently of where it lives.
+func WithPortableNames(enabled bool) Option {
+	return func(fs *FS) {
+		fs.portableNames = enabled
+	}
+}
+
+// checkPortable validates the part of `name` below the root, when
+// portable names are enforced.
+func (fs *FS) checkPortable(op string, name string) error {
+	if !fs.portableNames {
+		return nil
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(fs.strictRoot(abs), abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// outside of the root, only validate the base name
+		rel = filepath.Base(abs)
+	}
+	if rel == "." {
+		return nil
+	}
+	return wrap(ValidatePortablePath(rel), op, name)
+}
@@ -0,0 +1,147 @@
+package screw_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func portabilityReasons(err error) []screw.PortabilityReason {
+	var pe *screw.PortabilityError
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	var reasons []screw.PortabilityReason
+	for _, problem := range pe.Problems {
+		reasons = append(reasons, problem.Reason)
+	}
+	return reasons
+}
+
+func Test_ValidatePortableName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{
+		"apricot.png",
+		"Makefile",
+		".gitignore",
+		".",
+		"..",
+		"CONSOLE",
+		"COM10",
+		"LPT",
+		"nul_device",
+		"caf\u00e9",
+		"\U0001f34e.png",
+		strings.Repeat("a", 255),
+	} {
+		assert.NoError(screw.ValidatePortableName(name), "%q", name)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		reasons []screw.PortabilityReason
+	}{
+		{"CON", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"aux", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"CON.txt", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"nul.tar.gz", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"Com1", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"LPT0.log", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"COM\u00b9", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"lpt\u00b3.txt", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"PRN .txt", []screw.PortabilityReason{screw.PortabilityReservedName}},
+		{"foo.", []screw.PortabilityReason{screw.PortabilityTrailingDot}},
+		{"bar ", []screw.PortabilityReason{screw.PortabilityTrailingSpace}},
+		{"a<b", []screw.PortabilityReason{screw.PortabilityInvalidChar}},
+		{"what?", []screw.PortabilityReason{screw.PortabilityInvalidChar}},
+		{`C:\`, []screw.PortabilityReason{screw.PortabilityInvalidChar}},
+		{"a/b", []screw.PortabilityReason{screw.PortabilityInvalidChar}},
+		{"tab\there", []screw.PortabilityReason{screw.PortabilityControlChar}},
+		{"\xff.png", []screw.PortabilityReason{screw.PortabilityInvalidUTF8}},
+		{strings.Repeat("a", 256), []screw.PortabilityReason{screw.PortabilityNameTooLong}},
+		// 255 UTF-16 code units, but 510 bytes of UTF-8
+		{strings.Repeat("\u00e9", 255), []screw.PortabilityReason{screw.PortabilityNameTooLong}},
+		// 128 runes, but 256 UTF-16 code units
+		{strings.Repeat("\U0001f34e", 128), []screw.PortabilityReason{screw.PortabilityNameTooLong}},
+		{"aux.*: ", []screw.PortabilityReason{
+			screw.PortabilityReservedName,
+			screw.PortabilityInvalidChar,
+			screw.PortabilityTrailingSpace,
+		}},
+	} {
+		err := screw.ValidatePortableName(tc.name)
+		assert.Equal(tc.reasons, portabilityReasons(err), "%q", tc.name)
+		assert.True(screw.IsNotPortable(err))
+		assert.True(errors.Is(err, fs.ErrInvalid))
+	}
+}
+
+func Test_ValidatePortablePath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(screw.ValidatePortablePath("Data/textures/apricot.png"))
+	assert.NoError(screw.ValidatePortablePath("./Data/../readme.txt"))
+
+	err := screw.ValidatePortablePath("Data/CON/foo.")
+	assert.Equal([]screw.PortabilityReason{
+		screw.PortabilityReservedName,
+		screw.PortabilityTrailingDot,
+	}, portabilityReasons(err))
+	var pe *screw.PortabilityError
+	assert.True(errors.As(err, &pe))
+	assert.Equal("CON", pe.Problems[0].Name)
+	assert.Equal("foo.", pe.Problems[1].Name)
+	assert.Contains(err.Error(), `"CON": reserved device name`)
+
+	long := strings.Repeat(strings.Repeat("a", 99)+"/", 3)
+	assert.Equal([]screw.PortabilityReason{screw.PortabilityPathTooLong}, portabilityReasons(screw.ValidatePortablePath(long)))
+}
+
+func Test_PortableNames(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("home", "aux"), 0o755))
+	fs := screw.New(
+		screw.WithBackend(mem),
+		screw.WithRoot(memPath("home", "aux")),
+		screw.WithPortableNames(true),
+	)
+
+	// the root itself doesn't have to be portable
+	must(fs.WriteFile(memPath("home", "aux", "readme.txt"), nil, 0o644))
+	must(fs.Mkdir(memPath("home", "aux", "Data"), 0o755))
+
+	_, err := fs.Create(memPath("home", "aux", "CON.txt"))
+	assert.True(screw.IsNotPortable(err))
+	_, err = mem.Lstat(memPath("home", "aux", "CON.txt"))
+	assert.True(os.IsNotExist(err))
+
+	err = fs.Mkdir(memPath("home", "aux", "Data", "foo."), 0o755)
+	assert.True(screw.IsNotPortable(err))
+	var pathErr *os.PathError
+	assert.True(errors.As(err, &pathErr))
+	assert.Equal("screw.Mkdir", pathErr.Op)
+
+	assert.True(screw.IsNotPortable(fs.MkdirAll(memPath("home", "aux", "a|b", "c"), 0o755)))
+	assert.True(screw.IsNotPortable(fs.Rename(memPath("home", "aux", "readme.txt"), memPath("home", "aux", "read me "))))
+	assert.True(screw.IsNotPortable(fs.Symlink("readme.txt", memPath("home", "aux", "nul"))))
+
+	// reading is fine, and so is opening without O_CREATE
+	_, err = fs.Stat(memPath("home", "aux", "prn"))
+	assert.True(os.IsNotExist(err))
+	assert.False(screw.IsNotPortable(err))
+
+	// outside of the root, only the base name is validated
+	must(fs.Mkdir(memPath("home", "aux", "..", "ok"), 0o755))
+	assert.True(screw.IsNotPortable(fs.Mkdir(memPath("lpt1"), 0o755)))
+
+	// off by default
+	must(screw.New(screw.WithBackend(mem)).Mkdir(memPath("home", "aux", "foo."), 0o755))
+}
@@ -0,0 +1,260 @@
+package screw
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode"
+)
+
+// IsCaseInsensitiveDir returns true if the filesystem `dir` is on treats
+// names case-insensitively. Unlike IsCaseInsensitiveFS, it probes the
+// actual directory, so it gets case-insensitive Linux folders and
+// case-sensitive macOS volumes right.
+//
+// Results are cached per device (or per directory, when the backend
+// doesn't expose device information).
+func (fs *FS) IsCaseInsensitiveDir(dir string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+
+	stats, err := fs.backend.Stat(dir)
+	if err != nil {
+		return false, err
+	}
+	if !stats.IsDir() {
+		return false, &os.PathError{Op: "screw.IsCaseInsensitiveDir", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	key := probeKey(dir, stats)
+	if result, ok := fs.probes.get(key); ok && result.conclusive {
+		return result.insensitive, nil
+	}
+	return fs.probe(dir, key, true)
+}
+
+// caseInsensitiveAt returns true if `name` lives in a case-insensitive
+// directory. Only operations that create entries (`write`) may probe
+// with a temporary file: others rely on existing entries, and when
+// those can't tell, on what the backend reports for the whole filesystem.
+func (fs *FS) caseInsensitiveAt(name string, write bool) bool {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return fs.backend.IsCaseInsensitive()
+	}
+
+	// probe the closest existing ancestor
+	dir := filepath.Dir(abs)
+	var stats os.FileInfo
+	for {
+		stats, err = fs.backend.Stat(dir)
+		if err == nil && stats.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if err == nil || !os.IsNotExist(err) || parent == dir {
+			return fs.backend.IsCaseInsensitive()
+		}
+		dir = parent
+	}
+
+	key := probeKey(dir, stats)
+	if result, ok := fs.probes.get(key); ok && (result.conclusive || result.wrote || !write) {
+		return result.insensitive
+	}
+	insensitive, _ := fs.probe(dir, key, write)
+	return insensitive
+}
+
+// probe finds out whether `dir` is case-insensitive, without writing
+// anything unless `write` is true, and caches the answer under `key`.
+// When it can't tell, it caches and returns what the backend reports
+// for the whole filesystem, along with the error the probe file ran
+// into, if any, so that the same directory isn't probed over and over.
+func (fs *FS) probe(dir string, key string, write bool) (bool, error) {
+	var insensitive, known bool
+	if _, ok := fs.backend.(OSBackend); ok {
+		insensitive, known = caseHint(dir)
+	}
+	if !known {
+		// avoid writing anything if we can
+		insensitive, known = fs.probeExisting(dir)
+	}
+	var err error
+	if !known && write {
+		insensitive, err = fs.probeWithFile(dir)
+		known = err == nil
+	}
+
+	if !known {
+		fallback := fs.backend.IsCaseInsensitive()
+		fs.debug("screw: could not probe case sensitivity", slog.String("dir", dir), slog.Bool("insensitive", fallback), slog.Any("error", err))
+		fs.probes.put(key, probeResult{insensitive: fallback, wrote: write})
+		if err != nil {
+			return false, err
+		}
+		return fallback, nil
+	}
+
+	fs.debug("screw: probed case sensitivity", slog.String("dir", dir), slog.Bool("insensitive", insensitive))
+	fs.probes.put(key, probeResult{insensitive: insensitive, conclusive: true})
+	return insensitive, nil
+}
+
+func probeKey(dir string, stats os.FileInfo) string {
+	if key := volumeKey(dir, stats); key != "" {
+		return key
+	}
+	return "dir:" + dir
+}
+
+// probeExisting looks for an entry of `dir` whose name contains letters,
+// and looks it up with its case swapped. `ok` is false if no such entry
+// was found, or if the directory can't be listed.
+func (fs *FS) probeExisting(dir string) (insensitive bool, ok bool) {
+	f, err := fs.backend.OpenFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return false, false
+	}
+	defer f.Close()
+
+	for {
+		names, err := f.Readdirnames(64)
+		for _, name := range names {
+			swapped := swapCase(name)
+			if swapped == name {
+				continue
+			}
+
+			original, err := fs.backend.Lstat(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			variant, err := fs.backend.Lstat(filepath.Join(dir, swapped))
+			if err != nil {
+				if os.IsNotExist(err) {
+					return false, true
+				}
+				continue
+			}
+
+			// both exist: either they're the same file, or the directory
+			// is case-sensitive and has both variants.
+			if os.SameFile(original, variant) || fs.backend.TrueBaseName(filepath.Join(dir, swapped)) == name {
+				return true, true
+			}
+			return false, true
+		}
+		if err != nil {
+			if err != io.EOF {
+				fs.debug("screw: could not list directory while probing", slog.String("dir", dir), slog.Any("error", err))
+			}
+			return false, false
+		}
+	}
+}
+
+// probeWithFile creates a uniquely-named file in `dir`, and checks
+// whether it can be found with its case swapped.
+func (fs *FS) probeWithFile(dir string) (bool, error) {
+	name := fmt.Sprintf(".Screw-Probe-%d-%x", os.Getpid(), rand.Int63())
+	probe := filepath.Join(dir, name)
+
+	f, err := fs.backend.OpenFile(probe, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+	defer fs.backend.Remove(probe)
+
+	_, err = fs.backend.Lstat(filepath.Join(dir, swapCase(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// probeResult is what's known about the case sensitivity of a volume
+// (or directory, see probeKey)
+type probeResult struct {
+	insensitive bool
+	// false if probing couldn't tell, in which case `insensitive`
+	// is what the backend reports for the whole filesystem
+	conclusive bool
+	// whether probing with a temporary file was attempted
+	wrote bool
+}
+
+// maximum number of directories probeCache holds results for, when
+// the backend doesn't expose device information
+const maxProbedDirs = 1024
+
+// probeCache holds probe results by key, see probeKey
+type probeCache struct {
+	mu      sync.Mutex
+	results map[string]probeResult
+	dirs    int
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{
+		results: make(map[string]probeResult),
+	}
+}
+
+func (pc *probeCache) get(key string) (probeResult, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	result, ok := pc.results[key]
+	return result, ok
+}
+
+func (pc *probeCache) put(key string, result probeResult) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if _, ok := pc.results[key]; !ok && strings.HasPrefix(key, "dir:") {
+		if pc.dirs >= maxProbedDirs {
+			// per-directory results could grow without bounds:
+			// start over rather than keep them all.
+			for k := range pc.results {
+				if strings.HasPrefix(k, "dir:") {
+					delete(pc.results, k)
+				}
+			}
+			pc.dirs = 0
+		}
+		pc.dirs++
+	}
+	pc.results[key] = result
+}
+
+func swapCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			runes[i] = unicode.ToLower(r)
+		} else if unicode.IsLower(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// IsCaseInsensitiveDir returns true if the filesystem `dir` is on
+// treats names case-insensitively, see (*FS).IsCaseInsensitiveDir
+func IsCaseInsensitiveDir(dir string) (bool, error) {
+	return Default().IsCaseInsensitiveDir(dir)
+}
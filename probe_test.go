@@ -0,0 +1,165 @@
+package screw_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// probingBackend counts the files opened and the TrueBaseName lookups
+// made on the real filesystem
+type probingBackend struct {
+	screw.OSBackend
+	opens   int
+	lookups int
+}
+
+func (pb *probingBackend) OpenFile(name string, flag int, perm os.FileMode) (screw.File, error) {
+	pb.opens++
+	return pb.OSBackend.OpenFile(name, flag, perm)
+}
+
+func (pb *probingBackend) TrueBaseName(name string) string {
+	pb.lookups++
+	return pb.OSBackend.TrueBaseName(name)
+}
+
+func Test_IsCaseInsensitiveDir_Mem(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("empty"), 0o755))
+	must(mem.MkdirAll(memPath("full"), 0o755))
+	writeMem(mem, memPath("full", "Apricot"), "apricot")
+	fsys := screw.New(screw.WithBackend(mem))
+
+	insensitive, err := fsys.IsCaseInsensitiveDir(memPath("full"))
+	assert.NoError(err)
+	assert.True(insensitive)
+
+	// empty directories are probed with a temporary file
+	insensitive, err = fsys.IsCaseInsensitiveDir(memPath("empty"))
+	assert.NoError(err)
+	assert.True(insensitive)
+	dir, err := mem.OpenFile(memPath("empty"), os.O_RDONLY, 0)
+	must(err)
+	names, err := dir.Readdirnames(-1)
+	must(err)
+	must(dir.Close())
+	assert.Empty(names, "must clean up the probe")
+
+	_, err = fsys.IsCaseInsensitiveDir(memPath("nope"))
+	assert.True(os.IsNotExist(err))
+
+	_, err = fsys.IsCaseInsensitiveDir(memPath("full", "Apricot"))
+	assert.Error(err)
+}
+
+func Test_IsCaseInsensitiveDir_OS(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := t.TempDir()
+	must(os.Mkdir(filepath.Join(tmpDir, "a"), 0o755))
+	must(os.Mkdir(filepath.Join(tmpDir, "b"), 0o755))
+
+	pb := &probingBackend{}
+	fsys := screw.New(screw.WithBackend(pb))
+
+	insensitive, err := fsys.IsCaseInsensitiveDir(filepath.Join(tmpDir, "a"))
+	assert.NoError(err)
+	assert.EqualValues(runtime.GOOS != "linux", insensitive)
+	assert.NotZero(pb.opens)
+
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "a"))
+	must(err)
+	assert.Empty(entries, "must clean up the probe")
+
+	// the answer is cached per device
+	pb.opens = 0
+	insensitive, err = fsys.IsCaseInsensitiveDir(filepath.Join(tmpDir, "b"))
+	assert.NoError(err)
+	assert.EqualValues(runtime.GOOS != "linux", insensitive)
+	assert.Zero(pb.opens)
+}
+
+func Test_IsCaseInsensitiveDir_SkipsChecks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs a case-sensitive temporary directory")
+	}
+	assert := assert.New(t)
+
+	tmpDir := t.TempDir()
+	must(os.WriteFile(filepath.Join(tmpDir, "apricot"), []byte("apricot"), 0o644))
+
+	pb := &probingBackend{}
+	fsys := screw.New(screw.WithBackend(pb))
+
+	_, err := fsys.Stat(filepath.Join(tmpDir, "apricot"))
+	must(err)
+
+	// names can't have the wrong case in case-sensitive directories,
+	// so they're not looked up.
+	pb.lookups = 0
+	_, err = fsys.Stat(filepath.Join(tmpDir, "apricot"))
+	must(err)
+	assert.Zero(pb.lookups)
+}
+
+// readOnlyBackend is a MemBackend where nothing can be created, which
+// counts directory listings and attempts at creating probe files
+type readOnlyBackend struct {
+	*screw.MemBackend
+	listings int
+	probes   int
+}
+
+func (rb *readOnlyBackend) OpenFile(name string, flag int, perm os.FileMode) (screw.File, error) {
+	if strings.HasPrefix(filepath.Base(name), ".Screw-Probe-") {
+		rb.probes++
+	}
+	if flag&os.O_CREATE != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EROFS}
+	}
+	if stats, err := rb.MemBackend.Stat(name); err == nil && stats.IsDir() {
+		rb.listings++
+	}
+	return rb.MemBackend.OpenFile(name, flag, perm)
+}
+
+func Test_IsCaseInsensitiveDir_ReadOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	rb := &readOnlyBackend{MemBackend: screw.NewMemBackend()}
+	must(rb.MemBackend.MkdirAll(memPath("empty"), 0o755))
+	fsys := screw.New(screw.WithBackend(rb))
+
+	// reading never writes probe files, and what the backend reports
+	// is remembered when existing entries can't tell.
+	for i := 0; i < 3; i++ {
+		_, err := fsys.Stat(memPath("empty", "apricot"))
+		assert.True(os.IsNotExist(err))
+	}
+	assert.Zero(rb.probes)
+	assert.EqualValues(1, rb.listings)
+
+	// creating may probe with a file, but a failed probe isn't retried
+	rb.listings = 0
+	for i := 0; i < 3; i++ {
+		_, err := fsys.Create(memPath("empty", "apricot"))
+		assert.True(errors.Is(err, syscall.EROFS))
+	}
+	assert.EqualValues(1, rb.probes)
+	assert.EqualValues(1, rb.listings)
+
+	// asking explicitly does probe again, and reports the failure
+	_, err := fsys.IsCaseInsensitiveDir(memPath("empty"))
+	assert.True(errors.Is(err, syscall.EROFS))
+	assert.EqualValues(2, rb.probes)
+}
@@ -0,0 +1,77 @@
+package screw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// racingBackend runs `beforeOpen` right before every OpenFile, to
+// simulate another process changing things under our feet.
+type racingBackend struct {
+	*screw.MemBackend
+	beforeOpen func()
+}
+
+func (rb *racingBackend) OpenFile(name string, flag int, perm os.FileMode) (screw.File, error) {
+	if rb.beforeOpen != nil {
+		rb.beforeOpen()
+		rb.beforeOpen = nil
+	}
+	return rb.MemBackend.OpenFile(name, flag, perm)
+}
+
+func Test_Race_RenamedBeforeOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	rb := &racingBackend{MemBackend: screw.NewMemBackend()}
+	must(rb.MkdirAll(memPath(), 0o755))
+	writeMem(rb.MemBackend, memPath("apricot"), "apricot")
+
+	fsys := screw.New(screw.WithBackend(rb))
+	rb.beforeOpen = func() {
+		must(rb.MemBackend.Rename(memPath("apricot"), memPath("APRICOT")))
+	}
+
+	_, err := fsys.Open(memPath("apricot"))
+	assert.True(os.IsNotExist(err), "must not open APRICOT, got %+v", err)
+}
+
+func Test_Race_CreatedBeforeCreate(t *testing.T) {
+	assert := assert.New(t)
+
+	rb := &racingBackend{MemBackend: screw.NewMemBackend()}
+	must(rb.MkdirAll(memPath(), 0o755))
+
+	fsys := screw.New(screw.WithBackend(rb))
+	rb.beforeOpen = func() {
+		writeMem(rb.MemBackend, memPath("APRICOT"), "precious")
+	}
+
+	_, err := fsys.Create(memPath("apricot"))
+	assert.True(screw.IsCaseConflict(err), "must not create over APRICOT, got %+v", err)
+	assert.EqualValues("precious", readMem(rb.MemBackend, memPath("APRICOT")), "APRICOT must not be truncated")
+	assert.EqualValues("APRICOT", rb.TrueBaseName(memPath("apricot")))
+}
+
+func Test_Race_TruncateAfterVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("APRICOT"), "precious")
+	writeMem(mem, memPath("banana"), "banana")
+
+	fsys := screw.New(screw.WithBackend(mem))
+
+	_, err := fsys.OpenFile(memPath("apricot"), os.O_WRONLY|os.O_TRUNC, 0)
+	assert.True(os.IsNotExist(err))
+	assert.EqualValues("precious", readMem(mem, memPath("APRICOT")), "APRICOT must not be truncated")
+
+	f, err := fsys.OpenFile(memPath("banana"), os.O_WRONLY|os.O_TRUNC, 0)
+	must(err)
+	must(f.Close())
+	assert.EqualValues("", readMem(mem, memPath("banana")))
+}
@@ -0,0 +1,166 @@
+package screw
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ReplaceFile moves `src` over `dst`, like Rename, but also works when
+// `dst` is busy, for example because it's an executable that's still
+// running. In that case, `dst` is first renamed aside, then `src` is
+// moved in its place, and the aside file is removed. If it can't be
+// removed yet, it's kept for CleanupReplaced.
+//
+// `src` must exist with the exact case given, and `dst` may not exist
+// with a different case.
+func (fs *FS) ReplaceFile(src, dst string) error {
+	return fs.ReplaceFileContext(context.Background(), src, dst)
+}
+
+// ReplaceFileContext is like ReplaceFile, but stops retrying as soon as
+// ctx is done, returning ctx.Err() joined with the last error encountered.
+func (fs *FS) ReplaceFileContext(ctx context.Context, src, dst string) (err error) {
+	rec, err := fs.begin("screw.ReplaceFile", slog.String("src", src), slog.String("dst", dst))
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	ctx = rec.context(ctx)
+
+	if src, err = fs.resolveCase("screw.ReplaceFile", src); err != nil {
+		return err
+	}
+	if dst, err = fs.resolveDest("screw.ReplaceFile", dst, src); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.ReplaceFile", src, os.ErrNotExist); err != nil {
+		return err
+	}
+	if err := fs.checkCase("screw.ReplaceFile", dst, ErrCaseConflict); err != nil {
+		return err
+	}
+	defer fs.forgetCase(src)
+	defer fs.forgetCase(dst)
+
+	// busy files don't get any less busy by waiting,
+	// so those aren't retried.
+	busy := false
+	err = fs.retryOp(ctx, func() error {
+		err := fs.backend.Rename(src, dst)
+		if err != nil && isBusy(err) {
+			busy = true
+			return nil
+		}
+		return err
+	})
+	if err != nil || !busy {
+		return err
+	}
+
+	aside := asidePath(dst)
+	fs.debug("screw: destination is busy, moving it aside", slog.String("dst", dst), slog.String("aside", aside))
+	err = fs.rename(ctx, dst, aside)
+	if err != nil {
+		return err
+	}
+
+	err = fs.rename(ctx, src, dst)
+	if err != nil {
+		// put things back the way they were
+		if restoreErr := fs.rename(context.Background(), aside, dst); restoreErr != nil {
+			fs.debug("screw: could not restore destination", slog.String("dst", dst), slog.Any("error", restoreErr))
+		}
+		return err
+	}
+
+	if err := fs.backend.Remove(aside); err != nil {
+		fs.debug("screw: could not remove replaced file yet", slog.String("aside", aside), slog.Any("error", err))
+		fs.replaced.add(aside)
+	}
+	return nil
+}
+
+// CleanupReplaced removes the files ReplaceFile had to leave behind
+// because they were still busy. Files that still can't be removed are
+// kept for a later call, and the first error is returned.
+func (fs *FS) CleanupReplaced() (err error) {
+	rec, err := fs.begin("screw.CleanupReplaced")
+	defer rec.done(&err)
+	if err != nil {
+		return err
+	}
+
+	for _, aside := range fs.replaced.take() {
+		removeErr := fs.backend.Remove(aside)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			fs.debug("screw: could not remove replaced file yet", slog.String("aside", aside), slog.Any("error", removeErr))
+			fs.replaced.add(aside)
+			if err == nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
+
+// PendingReplaced returns the files ReplaceFile had to leave behind,
+// see CleanupReplaced.
+func (fs *FS) PendingReplaced() []string {
+	return fs.replaced.list()
+}
+
+// asidePath returns a name next to `name` that a busy file can be
+// renamed to. It stays in the same directory, so that the rename
+// doesn't cross devices.
+func asidePath(name string) string {
+	return filepath.Join(filepath.Dir(name), fmt.Sprintf(".%s.screw-old-%d-%x", filepath.Base(name), os.Getpid(), rand.Int63()))
+}
+
+type replacedList struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (rl *replacedList) add(path string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.paths = append(rl.paths, path)
+}
+
+func (rl *replacedList) list() []string {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return append([]string(nil), rl.paths...)
+}
+
+func (rl *replacedList) take() []string {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	paths := rl.paths
+	rl.paths = nil
+	return paths
+}
+
+// ReplaceFile moves `src` over `dst`, even if `dst` is busy,
+// see (*FS).ReplaceFile
+func ReplaceFile(src, dst string) error {
+	return Default().ReplaceFile(src, dst)
+}
+
+// ReplaceFileContext is like ReplaceFile, but stops retrying as soon as
+// ctx is done, see (*FS).ReplaceFileContext
+func ReplaceFileContext(ctx context.Context, src, dst string) error {
+	return Default().ReplaceFileContext(ctx, src, dst)
+}
+
+// CleanupReplaced removes the files ReplaceFile had to leave behind,
+// see (*FS).CleanupReplaced
+func CleanupReplaced() error {
+	return Default().CleanupReplaced()
+}
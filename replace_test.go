@@ -0,0 +1,224 @@
+package screw_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// busyBackend refuses to replace or remove busy files, like Windows
+// does with running executables
+type busyBackend struct {
+	*screw.MemBackend
+	busy map[string]bool
+}
+
+func (bb *busyBackend) Rename(oldpath, newpath string) error {
+	if bb.busy[newpath] {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ETXTBSY}
+	}
+	if bb.busy[oldpath] {
+		// renaming busy files is fine, they stay busy
+		delete(bb.busy, oldpath)
+		bb.busy[newpath] = true
+	}
+	return bb.MemBackend.Rename(oldpath, newpath)
+}
+
+func (bb *busyBackend) Remove(name string) error {
+	if bb.busy[name] {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ETXTBSY}
+	}
+	return bb.MemBackend.Remove(name)
+}
+
+func Test_ReplaceFile_Busy(t *testing.T) {
+	assert := assert.New(t)
+
+	bb := &busyBackend{MemBackend: screw.NewMemBackend(), busy: map[string]bool{}}
+	must(bb.MkdirAll(memPath(), 0o755))
+	writeMem(bb.MemBackend, memPath("game"), "old")
+	writeMem(bb.MemBackend, memPath("game.new"), "new")
+	bb.busy[memPath("game")] = true
+
+	var calls []string
+	hook := &recordingHook{name: "hook", calls: &calls}
+	fsys := screw.New(screw.WithBackend(bb), screw.WithHook(hook))
+	must(fsys.ReplaceFile(memPath("game.new"), memPath("game")))
+	assert.EqualValues("new", readMem(bb.MemBackend, memPath("game")))
+
+	_, err := bb.Stat(memPath("game.new"))
+	assert.True(os.IsNotExist(err))
+
+	// the old file is still busy, so it's left aside
+	pending := fsys.PendingReplaced()
+	if assert.Len(pending, 1) {
+		assert.EqualValues("old", readMem(bb.MemBackend, pending[0]))
+	}
+	assert.Error(fsys.CleanupReplaced())
+	assert.Len(fsys.PendingReplaced(), 1)
+
+	// once it's no longer busy, it can be cleaned up
+	bb.busy = map[string]bool{}
+	assert.NoError(fsys.CleanupReplaced())
+	assert.Empty(fsys.PendingReplaced())
+	_, err = bb.Stat(pending[0])
+	assert.True(os.IsNotExist(err))
+
+	// cleanups are operations like any other
+	assert.EqualValues([]string{
+		"hook.Before screw.ReplaceFile",
+		"hook.After screw.ReplaceFile",
+		"hook.Before screw.CleanupReplaced",
+		"hook.After screw.CleanupReplaced",
+		"hook.Before screw.CleanupReplaced",
+		"hook.After screw.CleanupReplaced",
+	}, calls)
+	if assert.Len(hook.events, 3) {
+		assert.Error(hook.events[1].Err)
+		assert.NoError(hook.events[2].Err)
+	}
+}
+
+func Test_ReplaceFile_Case(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath(), 0o755))
+	writeMem(mem, memPath("GAME"), "old")
+	writeMem(mem, memPath("game.new"), "new")
+	fsys := screw.New(screw.WithBackend(mem))
+
+	err := fsys.ReplaceFile(memPath("game.new"), memPath("game"))
+	assert.True(screw.IsCaseConflict(err))
+
+	err = fsys.ReplaceFile(memPath("GAME.NEW"), memPath("GAME"))
+	assert.True(os.IsNotExist(err))
+
+	must(fsys.ReplaceFile(memPath("game.new"), memPath("GAME")))
+	assert.EqualValues("new", readMem(mem, memPath("GAME")))
+}
+
+func Test_ReplaceFile_Protected(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "game.new")
+	must(os.WriteFile(src, []byte("new"), 0o755))
+	fsys := screw.New()
+
+	// directories aren't busy, they're never replaced by a file
+	dir := filepath.Join(tmpDir, "game")
+	must(os.Mkdir(dir, 0o755))
+	assert.Error(fsys.ReplaceFile(src, dir))
+	stats, err := os.Stat(dir)
+	must(err)
+	assert.True(stats.IsDir())
+	assert.Empty(fsys.PendingReplaced())
+
+	if runtime.GOOS == "windows" {
+		// and read-only files aren't busy either, they stay protected
+		readOnly := filepath.Join(tmpDir, "readonly")
+		must(os.WriteFile(readOnly, []byte("old"), 0o444))
+		defer os.Chmod(readOnly, 0o644)
+		assert.Error(fsys.ReplaceFile(src, readOnly))
+		contents, err := os.ReadFile(readOnly)
+		must(err)
+		assert.EqualValues("old", string(contents))
+		assert.Empty(fsys.PendingReplaced())
+	}
+}
+
+func Test_ReplaceFile_HelperProcess(t *testing.T) {
+	if os.Getenv("SCREW_HELPER_PROCESS") != "1" {
+		t.Skip("only runs as a helper process")
+	}
+	// signal we're running, then wait to be told to exit
+	os.Stdout.WriteString("ready\n")
+	io.Copy(io.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+// On Linux, a running executable can't be written to, but it can be
+// renamed over, so this only exercises a plain rename there. On Windows,
+// it can't be renamed over either, so this exercises the real fallback:
+// the executable is moved aside and left for CleanupReplaced.
+func Test_ReplaceFile_RunningExecutable(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("only runs on Linux and Windows")
+	}
+	assert := assert.New(t)
+
+	self, err := os.Executable()
+	must(err)
+	selfBytes, err := os.ReadFile(self)
+	must(err)
+
+	tmpDir := t.TempDir()
+	game := filepath.Join(tmpDir, "game")
+	if runtime.GOOS == "windows" {
+		game += ".exe"
+	}
+	must(os.WriteFile(game, selfBytes, 0o755))
+
+	cmd := exec.Command(game, "-test.run=^Test_ReplaceFile_HelperProcess$")
+	cmd.Env = append(os.Environ(), "SCREW_HELPER_PROCESS=1")
+	stdin, err := cmd.StdinPipe()
+	must(err)
+	stdout, err := cmd.StdoutPipe()
+	must(err)
+	must(cmd.Start())
+	running := true
+	stop := func() {
+		if running {
+			stdin.Close()
+			cmd.Wait()
+			running = false
+		}
+	}
+	defer stop()
+	_, err = bufio.NewReader(stdout).ReadString('\n')
+	must(err)
+
+	if runtime.GOOS == "linux" {
+		// writing over a running executable fails...
+		err = os.WriteFile(game, []byte("new"), 0o755)
+		assert.True(errors.Is(err, syscall.ETXTBSY), "expected text file busy, got %+v", err)
+	}
+
+	// replacing it works either way
+	fsys := screw.New()
+	must(os.WriteFile(filepath.Join(tmpDir, "game.new"), []byte("new"), 0o755))
+	assert.NoError(fsys.ReplaceFile(filepath.Join(tmpDir, "game.new"), game))
+
+	contents, err := os.ReadFile(game)
+	must(err)
+	assert.EqualValues("new", string(contents))
+
+	if runtime.GOOS == "linux" {
+		assert.Empty(fsys.PendingReplaced(), "renaming over a running executable works on Linux")
+		return
+	}
+
+	// the old executable is still running, so it can't be removed yet
+	pending := fsys.PendingReplaced()
+	assert.Len(pending, 1)
+	assert.Error(fsys.CleanupReplaced())
+
+	stop()
+	assert.NoError(fsys.CleanupReplaced())
+	assert.Empty(fsys.PendingReplaced())
+	for _, aside := range pending {
+		_, err = os.Stat(aside)
+		assert.True(os.IsNotExist(err))
+	}
+}
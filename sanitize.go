@@ -0,0 +1,219 @@
+package screw
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// longest extension kept when shortening a name
+const maxPortableExt = 32
+
+// SanitizePortableName returns a version of `name`, a single path
+// component, that passes ValidatePortableName:
+//
+//   - invalid characters, control characters and invalid UTF-8 are
+//     escaped as %XX (so "what?" becomes "what%3F")
+//   - names longer than 255 bytes are shortened, keeping the extension
+//   - trailing dots and spaces are trimmed, and names left empty, like
+//     "..", become "_"
+//   - reserved names get an underscore after their stem ("CON.txt"
+//     becomes "CON_.txt")
+//
+// Portable names are returned unchanged.
+func SanitizePortableName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		if (r == utf8.RuneError && size == 1) || r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			for _, b := range []byte(name[i : i+size]) {
+				fmt.Fprintf(&sb, "%%%02X", b)
+			}
+		} else {
+			sb.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	name = sb.String()
+
+	for {
+		if len(name) > maxPortableName {
+			name = shorten(name, "", maxPortableName)
+		}
+		name = strings.TrimRight(name, ". ")
+		if name == "" {
+			return "_"
+		}
+		if !isReservedName(name) {
+			return name
+		}
+		stem, ext, found := strings.Cut(name, ".")
+		name = stem + "_"
+		if found {
+			name += "." + ext
+		}
+	}
+}
+
+// shorten returns `name` with `suffix` inserted before its extension,
+// cutting its stem so that the result fits in `max` bytes.
+func shorten(name string, suffix string, max int) string {
+	var ext string
+	if i := strings.LastIndexByte(name, '.'); i > 0 && len(name)-i <= maxPortableExt {
+		ext = name[i:]
+	}
+	stem := name[:len(name)-len(ext)]
+
+	n := max - len(suffix) - len(ext)
+	if n < len(stem) {
+		for n > 0 && !utf8.RuneStart(stem[n]) {
+			n--
+		}
+		stem = stem[:n]
+	}
+	return stem + suffix + ext
+}
+
+// SanitizePortablePaths maps each of `paths`, relative and separated by
+// forward slashes or the OS separator, to an equivalent that is safe to
+// create on every platform, and returns the mapping. Parents are
+// implied, like for FindPathCaseCollisions, and are mapped consistently:
+// `Data/a.png` and `Data/b.png` end up in the same directory.
+//
+// Each component goes through SanitizePortableName. Then, when several
+// entries of a directory would collide on any case-insensitive
+// filesystem (by the same rules as FindPathCaseCollisions, see
+// WithNormalization), the first one keeps its name. Colliding
+// directories are merged into it, so `Data/a.png` and `DATA/b.png` both
+// end up in `DATA`. Colliding files, and files and directories that
+// collide with each other, get a suffix derived from their original name
+// instead, like "readme~3b1f0a2c.txt". Names that didn't need sanitizing
+// come first, then names sort in byte order, so the result only depends
+// on the set of paths, not on their order.
+//
+// "." components and leading slashes are ignored, and ".." components
+// become "_", so that results never point outside of the directory they
+// are created in. Paths with no components are left out. The length of
+// whole paths isn't checked, since it depends on where they end up, see
+// ValidatePortablePath.
+func (fs *FS) SanitizePortablePaths(paths []string) map[string]string {
+	tree := newCollisionNode("")
+	leaves := make(map[string]*collisionNode)
+	for _, p := range paths {
+		node := tree
+		for _, part := range strings.Split(filepath.ToSlash(p), "/") {
+			if part == "" || part == "." {
+				continue
+			}
+			node = node.add(part)
+		}
+		if node != tree {
+			leaves[p] = node
+		}
+	}
+
+	sanitized := make(map[*collisionNode]string)
+	fs.sanitizeChildren([]*collisionNode{tree}, "", sanitized)
+
+	mapping := make(map[string]string, len(leaves))
+	for p, node := range leaves {
+		mapping[p] = sanitized[node]
+	}
+	return mapping
+}
+
+// sanitizeChildren records the sanitized paths of all the children of
+// `dirs`, which all end up as the same directory, whose sanitized path
+// is `dirPath`, and of their descendants.
+func (fs *FS) sanitizeChildren(dirs []*collisionNode, dirPath string, sanitized map[*collisionNode]string) {
+	type entry struct {
+		node  *collisionNode
+		name  string
+		clean string
+	}
+	var entries []entry
+	for _, dir := range dirs {
+		for name, child := range dir.children {
+			entries = append(entries, entry{node: child, name: name, clean: SanitizePortableName(name)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		iKept, jKept := entries[i].clean == entries[i].name, entries[j].clean == entries[j].name
+		if iKept != jKept {
+			return iKept
+		}
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return entries[i].node.path < entries[j].node.path
+	})
+
+	// a slot is a sanitized name, and if it's a directory, all the
+	// directories that were merged into it
+	type slot struct {
+		name string
+		dirs []*collisionNode
+	}
+	taken := make(map[string]*slot)
+	isTaken := func(name string) bool {
+		_, ok := taken[fs.portableKey(name)]
+		return ok
+	}
+	var slots []*slot
+	for _, e := range entries {
+		isDir := len(e.node.children) > 0
+		clean := e.clean
+		if s, ok := taken[fs.portableKey(clean)]; ok {
+			if isDir && len(s.dirs) > 0 {
+				// case variants of a directory become a single one
+				s.dirs = append(s.dirs, e.node)
+				sanitized[e.node] = path.Join(dirPath, s.name)
+				continue
+			}
+			clean = disambiguate(e.name, clean, isTaken)
+		}
+
+		s := &slot{name: clean}
+		if isDir {
+			s.dirs = []*collisionNode{e.node}
+		}
+		taken[fs.portableKey(clean)] = s
+		slots = append(slots, s)
+		sanitized[e.node] = path.Join(dirPath, clean)
+	}
+
+	for _, s := range slots {
+		if len(s.dirs) > 0 {
+			fs.sanitizeChildren(s.dirs, path.Join(dirPath, s.name), sanitized)
+		}
+	}
+}
+
+// disambiguate returns `clean`, the sanitized form of `name`, with
+// a suffix derived from `name`, that isn't taken yet.
+func disambiguate(name string, clean string, isTaken func(string) bool) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+
+	for i := 1; ; i++ {
+		suffix := "~" + hash
+		if i > 1 {
+			suffix = fmt.Sprintf("~%s-%d", hash, i)
+		}
+		candidate := shorten(clean, suffix, maxPortableName)
+		if !isTaken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// SanitizePortablePaths maps each of `paths` to an equivalent that is
+// safe to create on every platform, see (*FS).SanitizePortablePaths
+func SanitizePortablePaths(paths []string) map[string]string {
+	return Default().SanitizePortablePaths(paths)
+}
@@ -0,0 +1,112 @@
+package screw_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SanitizePortableName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{"apricot.png", "apricot.png"},
+		{".gitignore", ".gitignore"},
+		{"caf\u00e9", "caf\u00e9"},
+		{"what?", "what%3F"},
+		{`a<b>c:d"e|f*g\h/i`, "a%3Cb%3Ec%3Ad%22e%7Cf%2Ag%5Ch%2Fi"},
+		{"tab\there", "tab%09here"},
+		{"\xff.png", "%FF.png"},
+		{"foo.", "foo"},
+		{"bar . .", "bar"},
+		{"..", "_"},
+		{"   ", "_"},
+		{"CON", "CON_"},
+		{"con.txt", "con_.txt"},
+		{"aux.tar.gz", "aux_.tar.gz"},
+		{"COM\u00b9", "COM\u00b9_"},
+		{"nul.", "nul_"},
+		{"CON" + strings.Repeat(" ", 300), "CON_"},
+		{strings.Repeat("a", 300) + ".png", strings.Repeat("a", 251) + ".png"},
+		{strings.Repeat("\u00e9", 200), strings.Repeat("\u00e9", 127)},
+	} {
+		sanitized := screw.SanitizePortableName(tc.name)
+		assert.Equal(tc.expected, sanitized, "%q", tc.name)
+		assert.NoError(screw.ValidatePortableName(sanitized))
+	}
+}
+
+func Test_SanitizePortablePaths(t *testing.T) {
+	assert := assert.New(t)
+
+	paths := []string{
+		"Data/readme.txt",
+		"data/Textures/foo.png",
+		"DATA/textures/foo.png",
+		"README.txt",
+		"readme.txt",
+		"readme.txt",
+		"what?/CON",
+		"what%3F",
+		"stra\u00dfe",
+		"STRASSE",
+		"../evil.sh",
+		"./nested/./file",
+		"/",
+		"",
+	}
+	mapping := screw.SanitizePortablePaths(paths)
+	assert.EqualValues(map[string]string{
+		// case variants of a directory are merged, only files get suffixes
+		"Data/readme.txt":       "DATA/readme.txt",
+		"data/Textures/foo.png": "DATA/Textures/foo~c41bd6fe.png",
+		"DATA/textures/foo.png": "DATA/Textures/foo.png",
+		"README.txt":            "README.txt",
+		"readme.txt":            "readme~966dcb41.txt",
+		"what?/CON":             "what%3F~1d131530/CON_",
+		"what%3F":               "what%3F",
+		// collides on macOS, wherever this runs
+		"STRASSE":         "STRASSE",
+		"stra\u00dfe":     "stra\u00dfe~2626f818",
+		"../evil.sh":      "_/evil.sh",
+		"./nested/./file": "nested/file",
+	}, mapping)
+
+	// only depends on the set of paths
+	for i := 0; i < 10; i++ {
+		shuffled := append([]string(nil), paths...)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		assert.EqualValues(mapping, screw.SanitizePortablePaths(shuffled))
+	}
+
+	var results []string
+	for _, sanitized := range mapping {
+		assert.NoError(screw.ValidatePortablePath(sanitized))
+		results = append(results, sanitized)
+	}
+	assert.Empty(screw.FindPathCaseCollisions(results))
+}
+
+func Test_SanitizePortablePaths_Normalization(t *testing.T) {
+	assert := assert.New(t)
+
+	paths := []string{cafeNFC, cafeNFD}
+	assert.EqualValues(map[string]string{
+		cafeNFD: cafeNFD,
+		cafeNFC: cafeNFC + "~a82b5049",
+	}, screw.SanitizePortablePaths(paths))
+
+	distinct := screw.New(screw.WithNormalization(screw.NormalizationDistinct))
+	assert.EqualValues(map[string]string{
+		cafeNFD: cafeNFD,
+		cafeNFC: cafeNFC,
+	}, distinct.SanitizePortablePaths(paths))
+}
@@ -1,292 +1,276 @@
 package screw
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
 )
 
+// DEBUG enables debug output on stderr for FS instances that
+// weren't given a logger, see WithLogger.
 var DEBUG = os.Getenv("SCREW_DEBUG") == "1"
 
-var (
-	ErrCaseConflict = errors.New("a file with a different case already exists on disk")
-)
-
 // Returns true if `name` exists on disk but
 // with a different case.
 // Returns false in any other case.
 func IsWrongCase(name string) bool {
-	name, err := filepath.Abs(name)
-	if err != nil {
-		return false
-	}
-	trueBase := TrueBaseName(name)
-	if trueBase != "" && trueBase != filepath.Base(name) {
-		return true
-	}
-	return false
+	return Default().IsWrongCase(name)
+}
+
+// TrueBaseName returns the base name of `name` as it exists on disk,
+// see (*FS).TrueBaseName
+func TrueBaseName(name string) string {
+	return Default().TrueBaseName(name)
+}
+
+// IsCaseInsensitiveFS returns true if the filesystem of the default FS
+// is case-insensitive as a whole, see (*FS).IsCaseInsensitiveFS
+func IsCaseInsensitiveFS() bool {
+	return Default().IsCaseInsensitiveFS()
 }
 
 func Create(name string) (*os.File, error) {
-	stackdebugf("screw.Create (%s)", name)
-	f, err := openFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
-	debugerr(err, "screw.Create (%s)", name)
-	return f, err
+	return osFile("screw.Create", name)(Default().Create(name))
 }
 
 func Open(name string) (*os.File, error) {
-	stackdebugf("screw.Open (%s)", name)
-	f, err := openFile(name, os.O_RDONLY, 0)
-	debugerr(err, "screw.Open (%s)", name)
-	return f, err
+	return osFile("screw.Open", name)(Default().Open(name))
 }
 
 func Symlink(oldname string, newname string) error {
-	stackdebugf("screw.Symlink (%s, %s)", oldname, newname)
-	err := os.Symlink(oldname, newname)
-	debugerr(err, "screw.Symlink (%s, %s)", oldname, newname)
-	return err
+	return Default().Symlink(oldname, newname)
 }
 
 func Truncate(name string, size int64) error {
-	stackdebugf("screw.Truncate (%s, %d)", name, size)
-	wrap := mkwrap("screw.Truncate", name)
+	return Default().Truncate(name, size)
+}
 
-	if IsWrongCase(name) {
-		return wrap(ErrCaseConflict)
-	}
+func Chmod(name string, mode os.FileMode) error {
+	return Default().Chmod(name, mode)
+}
 
-	err := os.Truncate(name, size)
-	debugerr(err, "screw.Truncate (%s, %d)", name, size)
-	return err
+func Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return Default().Chtimes(name, atime, mtime)
 }
 
 func Readlink(name string) (string, error) {
-	stackdebugf("screw.Readlink (%s)", name)
-	wrap := mkwrap("screw.Readlink", name)
-
-	if IsWrongCase(name) {
-		return "", wrap(os.ErrNotExist)
-	}
-
-	s, err := os.Readlink(name)
-	debugerr(err, "screw.Readlink (%s)", name)
-	return s, err
+	return Default().Readlink(name)
 }
 
 func ReadDir(dirname string) ([]os.FileInfo, error) {
-	stackdebugf("screw.ReadDir (%s)", dirname)
-	wrap := mkwrap("screw.ReadDir", dirname)
-
-	if IsWrongCase(dirname) {
-		return nil, wrap(os.ErrNotExist)
-	}
-
-	e, err := ioutil.ReadDir(dirname)
-	debugerr(err, "screw.ReadDir (%s)", dirname)
-	return e, err
+	return Default().ReadDir(dirname)
 }
 
 func ReadFile(filename string) ([]byte, error) {
-	stackdebugf("screw.ReadFile(%s)", filename)
-	wrap := mkwrap("screw.ReadFile", filename)
-
-	if IsWrongCase(filename) {
-		return nil, wrap(os.ErrNotExist)
-	}
-
-	return ioutil.ReadFile(filename)
+	return Default().ReadFile(filename)
 }
 
 func WriteFile(filename string, data []byte, perm os.FileMode) error {
-	stackdebugf("screw.WriteFile(%s)", filename)
-	wrap := mkwrap("screw.WriteFile", filename)
-
-	if IsWrongCase(filename) {
-		return wrap(ErrCaseConflict)
-	}
-
-	return ioutil.WriteFile(filename, data, perm)
+	return Default().WriteFile(filename, data, perm)
 }
 
 func Mkdir(name string, perm os.FileMode) error {
-	stackdebugf("screw.Mkdir(%s)", name)
-	wrap := mkwrap("screw.Mkdir", name)
-
-	if IsWrongCase(name) {
-		return wrap(ErrCaseConflict)
-	}
-
-	err := os.Mkdir(name, perm)
-	debugerr(err, "screw.Mkdir (%s) (0o%o)", name, perm)
-	return err
+	return Default().Mkdir(name, perm)
 }
 
 func MkdirAll(name string, perm os.FileMode) error {
-	stackdebugf("screw.MkdirAll (%s) (0o%o)", name, perm)
-	wrap := mkwrap("screw.MkdirAll", name)
-
-	if IsWrongCase(name) {
-		return wrap(ErrCaseConflict)
-	}
-
-	err := os.MkdirAll(name, perm)
-	debugerr(err, "screw.MkdirAll (%s) (0o%o)", name, perm)
-	return err
+	return Default().MkdirAll(name, perm)
 }
 
 func OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
-	stackdebugf("screw.OpenFile (%s) (0x%x) (0o%o)", name, flag, perm)
-	f, err := openFile(name, flag, perm)
-	debugerr(err, "screw.OpenFile (%s) (0x%x) (0o%o)", name, flag, perm)
-	return f, err
+	return osFile("screw.OpenFile", name)(Default().OpenFile(name, flag, perm))
 }
 
-func openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
-	wrap := mkwrap("screw.OpenFile", name)
+func Stat(name string) (os.FileInfo, error) {
+	return Default().Stat(name)
+}
 
-	if IsWrongCase(name) {
-		if (flag & os.O_CREATE) > 0 {
-			return nil, wrap(ErrCaseConflict)
-		} else {
-			return nil, wrap(os.ErrNotExist)
-		}
-	}
+func Lstat(name string) (os.FileInfo, error) {
+	return Default().Lstat(name)
+}
 
-	return os.OpenFile(name, flag, perm)
+func RemoveAll(name string) error {
+	return Default().RemoveAll(name)
 }
 
-func Stat(name string) (os.FileInfo, error) {
-	stackdebugf("screw.Stat (%s)", name)
-	wrap := mkwrap("screw.Stat", name)
+func Remove(name string) error {
+	return Default().Remove(name)
+}
 
-	if IsWrongCase(name) {
-		return nil, wrap(os.ErrNotExist)
-	}
+func Rename(oldpath, newpath string) error {
+	return Default().Rename(oldpath, newpath)
+}
 
-	s, err := os.Stat(name)
-	debugerr(err, "screw.Stat (%s)", name)
-	return s, err
+func RemoveAllContext(ctx context.Context, name string) error {
+	return Default().RemoveAllContext(ctx, name)
 }
 
-func Lstat(name string) (os.FileInfo, error) {
-	stackdebugf("screw.Lstat (%s)", name)
-	wrap := mkwrap("screw.Lstat", name)
+func RemoveContext(ctx context.Context, name string) error {
+	return Default().RemoveContext(ctx, name)
+}
 
-	if IsWrongCase(name) {
-		return nil, wrap(os.ErrNotExist)
+func RenameContext(ctx context.Context, oldpath, newpath string) error {
+	return Default().RenameContext(ctx, oldpath, newpath)
+}
+
+// osFile unwraps files opened by the default FS for operation `op`.
+// Backends other than the OS's may open other kinds of files, those
+// are closed, and ErrNotOSFile is returned.
+func osFile(op string, name string) func(f File, err error) (*os.File, error) {
+	return func(f File, err error) (*os.File, error) {
+		if err != nil {
+			return nil, err
+		}
+		osf, ok := f.(*os.File)
+		if !ok {
+			f.Close()
+			return nil, wrap(ErrNotOSFile, op, name)
+		}
+		return osf, nil
 	}
+}
+
+// opRecord tracks a single operation, so that hooks can see it
+// and it can be logged as one structured record once it's done.
+type opRecord struct {
+	fs    *FS
+	event Event
+	start time.Time
 
-	s, err := os.Lstat(name)
-	debugerr(err, "screw.Lstat (%s)", name)
-	return s, err
+	// how many hooks had their Before method called
+	hooked int
 }
 
-func RemoveAll(name string) error {
-	stackdebugf("screw.RemoveAll (%s)", name)
-	if IsWrongCase(name) {
-		// asked to remove "apricot" but "APRICOT" (or another case variant)
-		// exists, consider already removed
-		return nil
+// begin starts tracking operation `op`, and calls the Before method
+// of hooks, returning the first error one of them returned. It's meant
+// to be used as:
+//
+//	rec, err := fs.begin("screw.Stat", slog.String("path", name))
+//	defer doneWith(rec, &s, &err)
+//	if err != nil {
+//		return nil, err
+//	}
+func (fs *FS) begin(op string, args ...slog.Attr) (*opRecord, error) {
+	rec := &opRecord{
+		fs: fs,
+		event: Event{
+			Op:   op,
+			Args: args,
+		},
+		start: time.Now(),
+	}
+	for _, hook := range fs.hooks {
+		rec.hooked++
+		if err := hook.Before(&rec.event); err != nil {
+			return rec, err
+		}
 	}
+	return rec, nil
+}
 
-	err := os.RemoveAll(name)
-	debugerr(err, "screw.RemoveAll (%s)", name)
-	return err
+type opRecordKey struct{}
+
+// context returns a context that lets retryOp count retries
+// against this operation.
+func (r *opRecord) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, opRecordKey{}, r)
 }
 
-func Remove(name string) error {
-	debugf("screw.Remove (%s)", name)
-	wrap := mkwrap("screw.Remove", name)
+func recordFrom(ctx context.Context) *opRecord {
+	r, _ := ctx.Value(opRecordKey{}).(*opRecord)
+	return r
+}
 
-	if IsWrongCase(name) {
-		// asked to remove "apricot" but "APRICOT" (or another case variant)
-		// exists, so, can't remove "apricot" because it doesn't exist
-		return wrap(os.ErrNotExist)
-	}
+// done ends an operation that only returns an error, see end
+func (r *opRecord) done(errp *error) {
+	r.end(nil, *errp)
+}
 
-	// accepting to try and remove "apricot"
-	err := os.Remove(name)
-	debugerr(err, "screw.Remove (%s)", name)
-	return err
+// doneWith ends an operation that returns a result, see end
+func doneWith[T any](r *opRecord, result *T, errp *error) {
+	r.end(*result, *errp)
 }
 
-func Rename(oldpath, newpath string) error {
-	debugf("screw.Rename(%s, %s)", oldpath, newpath)
-	err := doRename(oldpath, newpath)
-	if err != nil {
-		return err
+// end calls the After method of hooks whose Before was called, in
+// reverse order, then logs
+// the operation along with its outcome.
+func (r *opRecord) end(result any, err error) {
+	r.event.Duration = time.Since(r.start)
+	r.event.Err = err
+	if err == nil {
+		r.event.Result = result
+	}
+	for i := r.hooked - 1; i >= 0; i-- {
+		r.fs.hooks[i].After(&r.event)
 	}
 
-	// case-only rename?
-	if strings.ToLower(oldpath) == strings.ToLower(newpath) {
-		// was it changed properly?
-		if TrueBaseName(newpath) != filepath.Base(newpath) {
-			tmppath := oldpath + fmt.Sprintf("_rename_%d", os.Getpid())
-			err := doRename(oldpath, tmppath)
-			if err != nil {
-				// here is an awkward place to return an error, but
-				// if anyone has a better idea, I'm listening.. :(
-				return err
-			}
-
-			err = doRename(tmppath, newpath)
-			if err != nil {
-				return err
-			}
-
-			return nil
-		}
+	logger := r.fs.log()
+	ctx := context.Background()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
 	}
 
-	return nil
+	attrs := append(slices.Clip(r.event.Args),
+		slog.Duration("duration", r.event.Duration),
+		slog.Int("retries", r.event.Retries),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if r.fs.stackTraces {
+		attrs = append(attrs, slog.String("stack", string(debug.Stack())))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, r.event.Op, attrs...)
 }
 
-func stackdebugf(f string, arg ...any) {
-	debugfex(true, f, arg...)
+func modeAttr(key string, mode os.FileMode) slog.Attr {
+	return slog.String(key, fmt.Sprintf("%#o", mode))
 }
 
-func debugf(f string, arg ...any) {
-	debugfex(false, f, arg...)
+func flagAttr(flag int) slog.Attr {
+	return slog.String("flag", fmt.Sprintf("%#x", flag))
 }
 
-func debugerr(err error, f string, arg ...any) {
-	if !DEBUG {
-		return
-	}
+// LevelTrace is the level details of operations in progress (retries,
+// probes, etc.) are logged at. It's below slog.LevelDebug, so that loggers
+// at debug level get exactly one record per operation.
+const LevelTrace = slog.LevelDebug - 4
 
-	line := fmt.Sprintf(f, arg...)
-	if err == nil {
-		debugf("[CHEERS] %s", line)
-	} else {
-		debugf("[SORROW] %s: %+v", line, err)
-	}
+// debug logs details of an operation in progress
+func (fs *FS) debug(msg string, attrs ...slog.Attr) {
+	fs.log().LogAttrs(context.Background(), LevelTrace, msg, attrs...)
 }
 
-func debugfex(stack bool, f string, arg ...any) {
-	if DEBUG {
-		line := fmt.Sprintf(f, arg...)
-		fmt.Printf("\n\n")
-		fmt.Printf("=============[screw]=============\n")
-		fmt.Printf("%s\n", line)
-		if stack {
-			stackLines := strings.SplitSeq(string(debug.Stack()), "\n")
-			for stackLine := range stackLines {
-				fmt.Printf("     -> %s\n", stackLine)
-			}
-		}
-		sneakyLog(line)
-	}
+var discardLogger = slog.New(slog.DiscardHandler)
+
+var debugLogger = slog.New(slog.NewTextHandler(io.MultiWriter(os.Stderr, sneakyWriter{}), &slog.HandlerOptions{
+	Level: slog.LevelDebug,
+}))
+
+// sneakyWriter forwards log lines to sneakyLog
+type sneakyWriter struct{}
+
+func (sneakyWriter) Write(p []byte) (int, error) {
+	sneakyLog(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
 }
 
-func mkwrap(op string, path string) func(err error) error {
-	return func(err error) error {
-		return wrap(err, op, path)
+// log returns where records should go. Without a logger, they're
+// discarded, unless DEBUG is set.
+func (fs *FS) log() *slog.Logger {
+	if fs.logger != nil {
+		return fs.logger
+	}
+	if DEBUG {
+		return debugLogger
 	}
+	return discardLogger
 }
 
 func wrap(err error, op string, path string) error {
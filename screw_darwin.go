@@ -7,6 +7,8 @@ package screw
 #cgo LDFLAGS: -framework Cocoa
 #import <Cocoa/Cocoa.h>
 #include <stdlib.h>
+#include <fcntl.h>
+#include <sys/param.h>
 
 char *GetCanonicalPath(char *cInputPath) {
 	NSString *inputPath = [NSString stringWithUTF8String:cInputPath];
@@ -23,6 +25,10 @@ char *GetCanonicalPath(char *cInputPath) {
 	memcpy(ret, tempString, strlen(tempString) + 1);
 	return ret;
 }
+
+int GetFdPath(int fd, char *buf) {
+	return fcntl(fd, F_GETPATH, buf);
+}
 */
 import "C"
 
@@ -31,6 +37,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
 	"unsafe"
 )
 
@@ -43,7 +50,7 @@ func sneakyLog(line string) {
 }
 
 // If `path` exists, and
-func TrueBaseName(path string) string {
+func osTrueBaseName(path string) string {
 	cInputPath := C.CString(path)
 	defer C.free(unsafe.Pointer(cInputPath))
 
@@ -57,6 +64,20 @@ func TrueBaseName(path string) string {
 	return filepath.Base(actualPath)
 }
 
+// fileTrueBaseName returns the on-disk name of an opened file
+func fileTrueBaseName(f *os.File) string {
+	var buf [C.MAXPATHLEN]byte
+	if C.GetFdPath(C.int(f.Fd()), (*C.char)(unsafe.Pointer(&buf[0]))) == -1 {
+		return ""
+	}
+
+	n := 0
+	for n < len(buf) && buf[n] != 0 {
+		n++
+	}
+	return filepath.Base(string(buf[:n]))
+}
+
 func doRename(oldpath, newpath string) error {
 	err := osRename(oldpath, newpath)
 	if err != nil {
@@ -89,6 +110,37 @@ func doRename(oldpath, newpath string) error {
 	return nil
 }
 
-func IsCaseInsensitiveFS() bool {
+func osIsCaseInsensitiveFS() bool {
 	return true
 }
+
+// platformFoldFlavor is APFS's. Older HFS+ volumes fold slightly
+// differently, see FoldHFSPlus.
+const platformFoldFlavor = FoldAPFS
+
+// shouldRetry returns true if an operation that failed with `err`
+// might succeed later. Files aren't locked on this platform, so
+// nothing is retried.
+func shouldRetry(err error) bool {
+	return false
+}
+
+// caseHint returns whether `dir` is case-insensitive when that's
+// known without probing. It never is on this platform.
+func caseHint(dir string) (insensitive bool, known bool) {
+	return false, false
+}
+
+// volumeKey identifies the device `dir` is on, see IsCaseInsensitiveDir
+func volumeKey(dir string, stats os.FileInfo) string {
+	if st, ok := stats.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("dev:%d", st.Dev)
+	}
+	return ""
+}
+
+// isBusy returns true if `err` means a file is in use, for example
+// because it's an executable that's running.
+func isBusy(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY) || errors.Is(err, syscall.EBUSY)
+}
@@ -2,25 +2,155 @@
 
 package screw
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 func sneakyLog(line string) {
 	// nothing
 }
 
 // If `path` exists, and
-func TrueBaseName(path string) string {
-	stats, err := os.Stat(path)
+func osTrueBaseName(path string) string {
+	stats, err := os.Lstat(path)
 	if err != nil {
 		return ""
 	}
+	if stats.Mode()&os.ModeSymlink != 0 {
+		// keep os.Stat's behavior for dangling symlinks
+		if _, err := os.Stat(path); err != nil {
+			return ""
+		}
+	}
+	if isCasefoldDir(filepath.Dir(path)) {
+		if name := scanTrueBaseName(filepath.Dir(path), filepath.Base(path), stats); name != "" {
+			return name
+		}
+	}
 	return stats.Name()
 }
 
-func IsCaseInsensitiveFS() bool {
+// fileTrueBaseName returns the on-disk name of an opened file. On a
+// case-sensitive filesystem, that's the name it was opened with.
+func fileTrueBaseName(f *os.File) string {
+	base := filepath.Base(f.Name())
+	dir := filepath.Dir(f.Name())
+	if !isCasefoldDir(dir) {
+		return base
+	}
+
+	stats, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	return scanTrueBaseName(dir, base, stats)
+}
+
+// fsCasefoldFL is FS_CASEFOLD_FL from linux/fs.h, set on directories
+// whose entries are looked up case-insensitively (`chattr +F`).
+const fsCasefoldFL = 0x40000000
+
+// getInodeFlags returns the inode flags of `path`, as reported by
+// the FS_IOC_GETFLAGS ioctl. Tests replace it to fake casefold
+// directories.
+var getInodeFlags = func(path string) (uint32, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	return unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+}
+
+// isCasefoldDir returns true if `dir` has the casefold attribute, ie.
+// is case-preserving and case-insensitive. Filesystems that don't
+// support inode flags aren't casefold.
+func isCasefoldDir(dir string) bool {
+	flags, err := getInodeFlags(dir)
+	if err != nil {
+		return false
+	}
+	return flags&fsCasefoldFL != 0
+}
+
+// scanTrueBaseName lists `dir` looking for the entry `base` resolves
+// to, which is the one that refers to the same file as `stats` (either
+// directly, or through a symlink). Returns "" if there is none.
+func scanTrueBaseName(dir string, base string, stats os.FileInfo) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !equalFold(entry.Name(), base) {
+			continue
+		}
+		entryPath := filepath.Join(dir, entry.Name())
+		entryStats, err := os.Lstat(entryPath)
+		if err != nil {
+			continue
+		}
+		if os.SameFile(entryStats, stats) {
+			return entry.Name()
+		}
+		if entryStats.Mode()&os.ModeSymlink != 0 {
+			if targetStats, err := os.Stat(entryPath); err == nil && os.SameFile(targetStats, stats) {
+				return entry.Name()
+			}
+		}
+	}
+	return ""
+}
+
+func osIsCaseInsensitiveFS() bool {
 	return false
 }
 
+// platformFoldFlavor is that of casefold directories
+const platformFoldFlavor = FoldAPFS
+
 func doRename(oldpath, newpath string) error {
 	return os.Rename(oldpath, newpath)
 }
+
+// shouldRetry returns true if an operation that failed with `err`
+// might succeed later. Files aren't locked on this platform, so
+// nothing is retried.
+func shouldRetry(err error) bool {
+	return false
+}
+
+// caseHint returns whether `dir` is case-insensitive when that's
+// known without probing: casefold directories are.
+func caseHint(dir string) (insensitive bool, known bool) {
+	if isCasefoldDir(dir) {
+		return true, true
+	}
+	return false, false
+}
+
+// volumeKey identifies the device `dir` is on, see IsCaseInsensitiveDir.
+// Casefold directories can share a device with case-sensitive ones,
+// so they get a key of their own.
+func volumeKey(dir string, stats os.FileInfo) string {
+	st, ok := stats.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	if isCasefoldDir(dir) {
+		return fmt.Sprintf("dev:%d:casefold", st.Dev)
+	}
+	return fmt.Sprintf("dev:%d", st.Dev)
+}
+
+// isBusy returns true if `err` means a file is in use, for example
+// because it's an executable that's running.
+func isBusy(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY) || errors.Is(err, syscall.EBUSY)
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package screw
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func stubCasefold(t *testing.T, dirs ...string) {
+	t.Helper()
+
+	previous := getInodeFlags
+	getInodeFlags = func(path string) (uint32, error) {
+		for _, dir := range dirs {
+			if path == dir {
+				return fsCasefoldFL, nil
+			}
+		}
+		return previous(path)
+	}
+	t.Cleanup(func() {
+		getInodeFlags = previous
+	})
+}
+
+func Test_Casefold_TrueBaseName(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// a hard link stands in for the case-insensitive lookup: in a real
+	// casefold directory, "apricot" would resolve to "Apricot".
+	actual := filepath.Join(tmpDir, "Apricot")
+	requested := filepath.Join(tmpDir, "apricot")
+	if err := os.WriteFile(actual, []byte("apricot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(actual, requested); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := osTrueBaseName(requested); got != "apricot" {
+		t.Errorf("without casefold, expected apricot, got %q", got)
+	}
+
+	stubCasefold(t, tmpDir)
+
+	if got := osTrueBaseName(requested); got != "Apricot" {
+		t.Errorf("expected Apricot, got %q", got)
+	}
+
+	f, err := os.Open(requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := fileTrueBaseName(f); got != "Apricot" {
+		t.Errorf("expected Apricot through the handle, got %q", got)
+	}
+
+	if !IsWrongCase(requested) {
+		t.Errorf("expected %s to have the wrong case", requested)
+	}
+	if _, err := Stat(requested); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %+v", err)
+	}
+	if _, err := Stat(actual); err != nil {
+		t.Errorf("expected no error, got %+v", err)
+	}
+}
+
+func Test_Casefold_Real(t *testing.T) {
+	// point this at a directory created with `chattr +F`
+	dir := os.Getenv("SCREW_CASEFOLD_DIR")
+	if dir == "" {
+		t.Skip("SCREW_CASEFOLD_DIR not set")
+	}
+
+	tmpDir, err := os.MkdirTemp(dir, "screw-casefold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if !isCasefoldDir(tmpDir) {
+		t.Fatalf("%s is not a casefold directory", tmpDir)
+	}
+
+	insensitive, err := IsCaseInsensitiveDir(tmpDir)
+	if err != nil || !insensitive {
+		t.Errorf("expected a case-insensitive directory, got %v, %+v", insensitive, err)
+	}
+
+	if err := WriteFile(filepath.Join(tmpDir, "Apricot"), []byte("apricot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := osTrueBaseName(filepath.Join(tmpDir, "APRICOT")); got != "Apricot" {
+		t.Errorf("expected Apricot, got %q", got)
+	}
+	if _, err := Open(filepath.Join(tmpDir, "apricot")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %+v", err)
+	}
+	if err := Rename(filepath.Join(tmpDir, "Apricot"), filepath.Join(tmpDir, "APRICOT")); err != nil {
+		t.Fatal(err)
+	}
+	if got := osTrueBaseName(filepath.Join(tmpDir, "apricot")); got != "APRICOT" {
+		t.Errorf("expected APRICOT after rename, got %q", got)
+	}
+	if got, err := Canonicalize(filepath.Join(tmpDir, "apricot")); err != nil || got != filepath.Join(tmpDir, "APRICOT") {
+		t.Errorf("expected canonical path to end in APRICOT, got %q, %+v", got, err)
+	}
+}
@@ -3,7 +3,10 @@
 package screw
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"golang.org/x/sys/windows"
 )
@@ -17,25 +20,62 @@ func sneakyLog(line string) {
 }
 
 func doRename(oldpath, newpath string) error {
-	var lastErr error
-	sleeper := newSleeper()
+	return os.Rename(oldpath, newpath)
+}
 
-	for {
-		err := os.Rename(oldpath, newpath)
-		lastErr = err
-		if err == nil {
-			break
-		}
+// shouldRetry returns true if an operation that failed with `err`
+// might succeed later, for example once an antivirus is done with a file:
+// that's sharing and lock violations, and "access denied" on entries that
+// aren't read-only (which is what a pending delete looks like).
+func shouldRetry(err error) bool {
+	if isPermanent(err) {
+		return false
+	}
+	switch {
+	case errors.Is(err, windows.ERROR_SHARING_VIOLATION),
+		errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		return true
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		return isTransientAccessDenied(err)
+	}
+	return false
+}
 
-		if !os.IsNotExist(err) && sleeper.Sleep(err) {
-			continue
+// isTransientAccessDenied returns true if the entry an "access denied"
+// error is about still exists, and isn't read-only: then it's locked
+// rather than protected. For renames, the destination, if any, must
+// also be a file that isn't read-only, since renaming over a directory
+// or a read-only file is denied too.
+func isTransientAccessDenied(err error) bool {
+	var pathErr *os.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		return writableStats(pathErr.Path) != nil
+	case errors.As(err, &linkErr):
+		if writableStats(linkErr.Old) == nil {
+			return false
+		}
+		if _, err := os.Lstat(linkErr.New); err != nil {
+			return true
 		}
-		break
+		stats := writableStats(linkErr.New)
+		return stats != nil && !stats.IsDir()
 	}
-	return lastErr
+	return false
 }
 
-func TrueBaseName(name string) string {
+// writableStats returns the stats of `name` if it exists and isn't
+// read-only, or nil otherwise.
+func writableStats(name string) os.FileInfo {
+	stats, err := os.Lstat(name)
+	if err != nil || stats.Mode().Perm()&0o200 == 0 {
+		return nil
+	}
+	return stats
+}
+
+func osTrueBaseName(name string) string {
 	var data windows.Win32finddata
 	utf16Str, err := windows.UTF16FromString(name)
 	if err != nil {
@@ -51,6 +91,60 @@ func TrueBaseName(name string) string {
 	return windows.UTF16ToString(data.FileName[:windows.MAX_PATH-1])
 }
 
-func IsCaseInsensitiveFS() bool {
+// fileTrueBaseName returns the on-disk name of an opened file
+func fileTrueBaseName(f *os.File) string {
+	buf := make([]uint16, windows.MAX_PATH)
+	for {
+		// flags = FILE_NAME_NORMALIZED | VOLUME_NAME_DOS
+		n, err := windows.GetFinalPathNameByHandle(windows.Handle(f.Fd()), &buf[0], uint32(len(buf)), 0)
+		if err != nil {
+			return ""
+		}
+		if int(n) < len(buf) {
+			return filepath.Base(windows.UTF16ToString(buf[:n]))
+		}
+		// buffer too small, n is the required size
+		buf = make([]uint16, n+1)
+	}
+}
+
+func osIsCaseInsensitiveFS() bool {
 	return true
 }
+
+const platformFoldFlavor = FoldNTFS
+
+// caseHint returns whether `dir` is case-insensitive when that's
+// known without probing. It never is on this platform.
+func caseHint(dir string) (insensitive bool, known bool) {
+	return false, false
+}
+
+// volumeKey identifies the volume `dir` is on, see IsCaseInsensitiveDir
+func volumeKey(dir string, stats os.FileInfo) string {
+	if vol := filepath.VolumeName(dir); vol != "" {
+		return "vol:" + strings.ToUpper(vol)
+	}
+	return ""
+}
+
+// isBusy returns true if `err` means a file is in use, for example
+// because it's an executable that's running.
+func isBusy(err error) bool {
+	switch {
+	case errors.Is(err, windows.ERROR_SHARING_VIOLATION),
+		errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		return true
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		// that's also what renaming over a directory or a read-only
+		// file gives, and those must not be moved aside: only
+		// writable, regular files can be busy.
+		var linkErr *os.LinkError
+		if !errors.As(err, &linkErr) {
+			return false
+		}
+		stats := writableStats(linkErr.New)
+		return stats != nil && stats.Mode().IsRegular()
+	}
+	return false
+}
@@ -0,0 +1,998 @@
+package screwtest
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/itchio/screw"
+)
+
+// FSKind is the kind of filesystem a TestCase applies to
+type FSKind string
+
+const (
+	FSAny             FSKind = ""
+	FSCaseSensitive   FSKind = "sensitive"
+	FSCaseInsensitive FSKind = "insensitive"
+)
+
+func (fsk FSKind) String() string {
+	switch fsk {
+	case FSCaseSensitive:
+		return "sensitive-fs"
+	case FSCaseInsensitive:
+		return "insensitive-fs"
+	default:
+		return "any-fs"
+	}
+}
+
+// TestCase is one row of the conformance table: a tree to set up, an
+// operation to call, and what must happen.
+type TestCase struct {
+	// name of test
+	Name string
+
+	// Dirs to create before this call
+	DirsBefore []string
+
+	// Files to create before this call
+	FilesBefore []string
+
+	// name of file to pass to operation
+	Argument string
+
+	// operation, see OpOpen, OpStat, OpRemove
+	Operation OpFunc
+
+	// if true, operation must succeed
+	Success bool
+
+	// if non-nil, operation must fail and the returned error's
+	// string representation should contain the string representation
+	// of this error
+	Error func(err error) bool
+
+	// Files that must exist after this call
+	// Note that existence will be checked ignoring case on Windows & Darwin
+	FilesAfter []string
+
+	// Dirs that must exist after this call
+	// Note that existence will be checked ignoring case on Windows & Darwin
+	DirsAfter []string
+
+	// Files/dirs that must *not* exist after this call
+	AbsentAfter []string
+
+	// if empty, test on all OSes
+	FSKind FSKind
+}
+
+// AssertValid fails `t` if the test case itself is malformed
+func (tc TestCase) AssertValid(t testing.TB) {
+	t.Helper()
+
+	if tc.Argument == "" {
+		t.Fatalf("invalid test %q: empty Argument", tc.Name)
+	}
+
+	if tc.Success && tc.Error != nil {
+		t.Fatalf("invalid test %q: both Success and Error specified", tc.Name)
+	}
+
+	if !tc.Success && tc.Error == nil {
+		t.Fatalf("invalid test %q: neither Success nor Error specified", tc.Name)
+	}
+}
+
+func (tc TestCase) ShouldRun(env Env) bool {
+	switch tc.FSKind {
+	case FSCaseInsensitive, FSCaseSensitive:
+		return tc.FSKind == env.Kind
+	default:
+		return true
+	}
+}
+
+func rawOpen(b screw.Backend) func(name string) (screw.File, error) {
+	return func(name string) (screw.File, error) {
+		return b.OpenFile(name, os.O_RDONLY, 0)
+	}
+}
+
+func rawCreate(b screw.Backend) func(name string) (screw.File, error) {
+	return func(name string) (screw.File, error) {
+		return b.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	}
+}
+
+func rawReadFile(b screw.Backend) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		f, err := b.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+}
+
+func rawWriteFile(b screw.Backend) func(name string, data []byte, perm os.FileMode) error {
+	return func(name string, data []byte, perm os.FileMode) error {
+		f, err := b.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(data)
+		return err
+	}
+}
+
+func rawReadDir(b screw.Backend) func(name string) ([]os.FileInfo, error) {
+	return func(name string) ([]os.FileInfo, error) {
+		f, err := b.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.Readdir(-1)
+	}
+}
+
+// Cases returns the conformance table for `env`. Cases that don't
+// apply to env.Kind are included, TestCase.ShouldRun filters them out.
+func Cases(env Env) []TestCase {
+	var testCases []TestCase
+
+	type opVariant struct {
+		name string
+		op   OpFunc
+	}
+
+	//==========================
+	// Stat, Lstat, Open, Truncate
+	//==========================
+
+	osVariants := []opVariant{
+		{
+			name: env.RawName + ".Stat",
+			op:   OpStat(env.Raw.Stat),
+		},
+		{
+			name: env.RawName + ".Lstat",
+			op:   OpStat(env.Raw.Lstat),
+		},
+		{
+			name: env.RawName + ".Open",
+			op:   OpOpen(rawOpen(env.Raw)),
+		},
+	}
+
+	for _, variant := range osVariants {
+		testCases = append(testCases, TestCase{
+			Name:      variant.name + "/nonexistent",
+			Argument:  "apricot",
+			Operation: variant.op,
+			Error:     os.IsNotExist,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/mixedcase",
+			FilesBefore: []string{"APRICOT"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Success:     true,
+
+			FSKind: FSCaseInsensitive,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/wrongcase",
+			FilesBefore: []string{"APRICOT"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Error:       os.IsNotExist,
+
+			FSKind: FSCaseSensitive,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/rightcase",
+			FilesBefore: []string{"apricot"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Success:     true,
+		})
+	}
+
+	screwVariants := []opVariant{
+		{
+			name: "screw.Stat",
+			op:   OpStat(env.FS.Stat),
+		},
+		{
+			name: "screw.Lstat",
+			op:   OpStat(env.FS.Lstat),
+		},
+		{
+			name: "screw.Open",
+			op:   OpOpen(env.FS.Open),
+		},
+	}
+
+	for _, variant := range screwVariants {
+		testCases = append(testCases, TestCase{
+			Name:      variant.name + "/nonexistent",
+			Argument:  "apricot",
+			Operation: variant.op,
+			Error:     os.IsNotExist,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/mixedcase",
+			FilesBefore: []string{"APRICOT"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Error:       os.IsNotExist,
+
+			FSKind: FSCaseInsensitive,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/wrongcase",
+			FilesBefore: []string{"APRICOT"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Error:       os.IsNotExist,
+
+			FSKind: FSCaseSensitive,
+		})
+		testCases = append(testCases, TestCase{
+			Name:        variant.name + "/rightcase",
+			FilesBefore: []string{"apricot"},
+			Argument:    "apricot",
+			Operation:   variant.op,
+			Success:     true,
+		})
+	}
+
+	//==========================
+	// ReadFile
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".ReadFile/nonexistent",
+		Argument:  "apricot",
+		Operation: OpReadFile(rawReadFile(env.Raw)),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".ReadFile/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpReadFile(rawReadFile(env.Raw)),
+		Success:     true,
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".ReadFile/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpReadFile(rawReadFile(env.Raw)),
+		Error:       os.IsNotExist,
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".ReadFile/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpReadFile(rawReadFile(env.Raw)),
+		Success:     true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.ReadFile/nonexistent",
+		Argument:  "apricot",
+		Operation: OpReadFile(env.FS.ReadFile),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.ReadFile/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpReadFile(env.FS.ReadFile),
+		Error:       os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.ReadFile/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpReadFile(env.FS.ReadFile),
+		Success:     true,
+	})
+
+	//==========================
+	// Truncate
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Truncate/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpTruncate(env.Raw.Truncate),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Truncate/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpTruncate(env.FS.Truncate),
+		Error:       ErrorIs(screw.ErrCaseConflict),
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	//==========================
+	// WriteFile
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".WriteFile/nonexistent",
+		Argument:  "apricot",
+		Operation: OpWriteFile(rawWriteFile(env.Raw)),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".WriteFile/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(rawWriteFile(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".WriteFile/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(rawWriteFile(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"apricot", "APRICOT"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".WriteFile/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(rawWriteFile(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"apricot"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.WriteFile/nonexistent",
+		Argument:   "apricot",
+		Operation:  OpWriteFile(env.FS.WriteFile),
+		Success:    true,
+		FilesAfter: []string{"apricot"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.WriteFile/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(env.FS.WriteFile),
+		Error:       ErrorIs(screw.ErrCaseConflict),
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.WriteFile/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(env.FS.WriteFile),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT", "apricot"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.WriteFile/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpWriteFile(env.FS.WriteFile),
+		Success:     true,
+		FilesAfter:  []string{"apricot"},
+	})
+
+	//==========================
+	// ReadDir
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".ReadDir/nonexistent",
+		Argument:  "apricot",
+		Operation: OpReadDir(rawReadDir(env.Raw)),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".ReadDir/mixedcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpReadDir(rawReadDir(env.Raw)),
+		Success:    true,
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".ReadDir/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpReadDir(rawReadDir(env.Raw)),
+		Error:      os.IsNotExist,
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".ReadDir/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpReadDir(rawReadDir(env.Raw)),
+		Success:    true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.ReadDir/nonexistent",
+		Argument:  "apricot",
+		Operation: OpReadDir(env.FS.ReadDir),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.ReadDir/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpReadDir(env.FS.ReadDir),
+		Error:      os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.ReadDir/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpReadDir(env.FS.ReadDir),
+		Success:    true,
+	})
+
+	//==========================
+	// Create
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Create/nonexistent",
+		Argument:   "apricot",
+		Operation:  OpCreate(rawCreate(env.Raw)),
+		Success:    true,
+		FilesAfter: []string{"apricot"},
+	})
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Create/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpCreate(rawCreate(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Create/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpCreate(rawCreate(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"apricot", "APRICOT"},
+
+		FSKind: FSCaseSensitive,
+	})
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Create/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpCreate(rawCreate(env.Raw)),
+		Success:     true,
+		FilesAfter:  []string{"apricot"},
+	})
+	testCases = append(testCases, TestCase{
+		Name:       "screw.Create/nonexistent",
+		Argument:   "apricot",
+		Operation:  OpCreate(env.FS.Create),
+		Success:    true,
+		FilesAfter: []string{"apricot"},
+	})
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Create/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpCreate(env.FS.Create),
+		Error:       ErrorIs(screw.ErrCaseConflict),
+		FilesAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Create/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpCreate(env.FS.Create),
+		Success:     true,
+		FilesAfter:  []string{"apricot", "APRICOT"},
+
+		FSKind: FSCaseSensitive,
+	})
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Create/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpCreate(env.FS.Create),
+		Success:     true,
+		FilesAfter:  []string{"apricot"},
+	})
+
+	//==========================
+	// Remove
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".Remove/nonexistent",
+		Argument:  "apricot",
+		Operation: OpRemove(env.Raw.Remove),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Remove/mixedcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.Remove),
+		Success:     true,
+		AbsentAfter: []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Remove/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.Remove),
+		Error:       os.IsNotExist,
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Remove/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.Remove),
+		Success:     true,
+		AbsentAfter: []string{"apricot"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.Remove/nonexistent",
+		Argument:  "apricot",
+		Operation: OpRemove(env.FS.Remove),
+		Error:     os.IsNotExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Remove/wrongcase",
+		FilesBefore: []string{"APRICOT"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.FS.Remove),
+		Error:       os.IsNotExist,
+		FilesAfter:  []string{"APRICOT"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Remove/rightcase",
+		FilesBefore: []string{"apricot"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.FS.Remove),
+		Success:     true,
+		AbsentAfter: []string{"apricot"},
+	})
+
+	//==========================
+	// RemoveAll
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".RemoveAll/nonexistent",
+		Argument:  "apricot",
+		Operation: OpRemove(env.Raw.RemoveAll),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".RemoveAll/mixedcase",
+		FilesBefore: []string{"APRICOT/README"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.RemoveAll),
+		AbsentAfter: []string{"APRICOT/README", "APRICOT"},
+		Success:     true,
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".RemoveAll/wrongcase",
+		FilesBefore: []string{"APRICOT/README"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.RemoveAll),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT/README"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".RemoveAll/rightcase",
+		FilesBefore: []string{"apricot/README"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.Raw.RemoveAll),
+		AbsentAfter: []string{"apricot/README", "apricot"},
+		Success:     true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.RemoveAll/nonexistent",
+		Argument:  "apricot",
+		Operation: OpRemove(env.FS.RemoveAll),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.RemoveAll/wrongcase",
+		FilesBefore: []string{"APRICOT/README"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.FS.RemoveAll),
+		Success:     true,
+		FilesAfter:  []string{"APRICOT/README"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.RemoveAll/rightcase",
+		FilesBefore: []string{"apricot/README"},
+		Argument:    "apricot",
+		Operation:   OpRemove(env.FS.RemoveAll),
+		AbsentAfter: []string{"apricot/README", "apricot"},
+		Success:     true,
+	})
+
+	//==========================
+	// Mkdir
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".Mkdir/nonexistent",
+		Argument:  "apricot",
+		Operation: OpMkdir(env.Raw.Mkdir),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Mkdir/mixedcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.Mkdir),
+		Error:      os.IsExist,
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Mkdir/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.Mkdir),
+		Success:    true,
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Mkdir/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.Mkdir),
+		Error:      os.IsExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Mkdir/nonexistentparent",
+		Argument:    "foo/bar",
+		Operation:   OpMkdir(env.Raw.Mkdir),
+		Error:       os.IsNotExist,
+		AbsentAfter: []string{"foo", "foo/bar"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Mkdir/mixedcaseparent",
+		DirsBefore: []string{"FOO"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.Raw.Mkdir),
+		Success:    true,
+		DirsAfter:  []string{"FOO", "FOO/bar"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        env.RawName + ".Mkdir/wrongcaseparent",
+		DirsBefore:  []string{"FOO"},
+		Argument:    "foo/bar",
+		Operation:   OpMkdir(env.Raw.Mkdir),
+		Error:       os.IsNotExist,
+		AbsentAfter: []string{"foo", "foo/bar"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".Mkdir/rightcaseparent",
+		DirsBefore: []string{"foo"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.Raw.Mkdir),
+		DirsAfter:  []string{"foo", "foo/bar"},
+		Success:    true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.Mkdir/nonexistent",
+		Argument:  "apricot",
+		Operation: OpMkdir(env.FS.Mkdir),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.Mkdir/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.Mkdir),
+		Error:      ErrorIs(screw.ErrCaseConflict),
+		DirsAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.Mkdir/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.Mkdir),
+		Success:    true,
+		DirsAfter:  []string{"APRICOT", "apricot"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.Mkdir/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.Mkdir),
+		Error:      os.IsExist,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Mkdir/nonexistentparent",
+		Argument:    "foo/bar",
+		Operation:   OpMkdir(env.FS.Mkdir),
+		Error:       os.IsNotExist,
+		AbsentAfter: []string{"foo", "foo/bar"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:        "screw.Mkdir/wrongcaseparent",
+		DirsBefore:  []string{"FOO"},
+		Argument:    "foo/bar",
+		Operation:   OpMkdir(env.FS.Mkdir),
+		Error:       os.IsNotExist,
+		AbsentAfter: []string{"foo", "foo/bar"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.Mkdir/rightcaseparent",
+		DirsBefore: []string{"foo"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.FS.Mkdir),
+		Success:    true,
+		DirsAfter:  []string{"foo", "foo/bar"},
+	})
+
+	//==========================
+	// MkdirAll
+	//==========================
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".MkdirAll/nonexistent",
+		Argument:  "apricot",
+		Operation: OpMkdir(env.Raw.MkdirAll),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/mixedcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"APRICOT", "apricot"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"apricot"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      env.RawName + ".MkdirAll/nonexistentparent",
+		Argument:  "foo/bar",
+		Operation: OpMkdir(env.Raw.MkdirAll),
+		Success:   true,
+		DirsAfter: []string{"foo", "foo/bar"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/mixedcaseparent",
+		DirsBefore: []string{"FOO"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"FOO", "foo/bar"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/wrongcaseparent",
+		DirsBefore: []string{"FOO"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"FOO", "foo/bar"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       env.RawName + ".MkdirAll/rightcaseparent",
+		DirsBefore: []string{"foo"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.Raw.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"foo", "foo/bar"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.MkdirAll/nonexistent",
+		Argument:  "apricot",
+		Operation: OpMkdir(env.FS.MkdirAll),
+		Success:   true,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Error:      ErrorIs(screw.ErrCaseConflict),
+		DirsAfter:  []string{"APRICOT"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/wrongcase",
+		DirsBefore: []string{"APRICOT"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"apricot", "APRICOT"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/rightcase",
+		DirsBefore: []string{"apricot"},
+		Argument:   "apricot",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"apricot"},
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:      "screw.MkdirAll/nonexistentparent",
+		Argument:  "foo/bar",
+		Operation: OpMkdir(env.FS.MkdirAll),
+		Success:   true,
+		DirsAfter: []string{"foo", "foo/bar"},
+	})
+
+	// we don't check for wrong-case parents
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/wrongcaseparent",
+		DirsBefore: []string{"FOO"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"FOO/bar"},
+
+		FSKind: FSCaseInsensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/wrongcaseparent",
+		DirsBefore: []string{"FOO"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"FOO", "foo/bar"},
+
+		FSKind: FSCaseSensitive,
+	})
+
+	testCases = append(testCases, TestCase{
+		Name:       "screw.MkdirAll/rightcaseparent",
+		DirsBefore: []string{"foo"},
+		Argument:   "foo/bar",
+		Operation:  OpMkdir(env.FS.MkdirAll),
+		Success:    true,
+		DirsAfter:  []string{"foo", "foo/bar"},
+	})
+
+	return testCases
+}
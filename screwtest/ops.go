@@ -0,0 +1,112 @@
+package screwtest
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrorIs returns a TestCase.Error function that checks errors.Is
+func ErrorIs(expected error) func(err error) bool {
+	return func(actual error) bool {
+		return errors.Is(actual, expected)
+	}
+}
+
+// OpFunc calls an operation on `name`, and returns whether it succeeded,
+// along with its error. The Op* functions adapt operations to it.
+type OpFunc func(name string) (bool, error)
+
+func OpOpen[F io.Closer](open func(name string) (F, error)) OpFunc {
+	return func(name string) (bool, error) {
+		f, err := open(name)
+		if err != nil {
+			return false, err
+		}
+
+		f.Close()
+		return true, nil
+	}
+}
+func OpTruncate(truncate func(name string, size int64) error) OpFunc {
+	return func(name string) (bool, error) {
+		err := truncate(name, 0)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func OpReadFile(readfile func(name string) ([]byte, error)) OpFunc {
+	return func(name string) (bool, error) {
+		_, err := readfile(name)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func OpWriteFile(writefile func(name string, data []byte, perm os.FileMode) error) OpFunc {
+	return func(name string) (bool, error) {
+		err := writefile(name, []byte("Hello"), 0o644)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func OpReadDir(readdir func(name string) ([]os.FileInfo, error)) OpFunc {
+	return func(name string) (bool, error) {
+		_, err := readdir(name)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func OpCreate[F io.Closer](create func(name string) (F, error)) OpFunc {
+	return func(name string) (bool, error) {
+		f, err := create(name)
+		if err != nil {
+			return false, err
+		}
+
+		f.Close()
+		return true, nil
+	}
+}
+
+func OpMkdir(mkdir func(name string, perm os.FileMode) error) OpFunc {
+	return func(name string) (bool, error) {
+		err := mkdir(name, 0o755)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+}
+
+func OpStat(stat func(name string) (os.FileInfo, error)) OpFunc {
+	return func(name string) (bool, error) {
+		_, err := stat(name)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func OpRemove(remove func(name string) error) OpFunc {
+	return func(name string) (bool, error) {
+		err := remove(name)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
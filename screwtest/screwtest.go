@@ -0,0 +1,275 @@
+// Package screwtest is the conformance suite for screw's case-sensible
+// semantics. It's what screw tests itself with, and it can be run against
+// other backends, or against wrappers of *screw.FS:
+//
+//	func Test_Conformance(t *testing.T) {
+//		screwtest.Run(t, screwtest.NewEnv(t, "mybackend", myBackend))
+//	}
+//
+// Wrappers replace the environment's FS with theirs, see FS.
+package screwtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Env is a filesystem the suite runs against
+type Env struct {
+	Name string
+	// Kind selects which cases apply, see FSKind
+	Kind FSKind
+
+	// raw operations, with the semantics of the underlying filesystem
+	Raw screw.Backend
+	// what raw operations are called in test names
+	RawName string
+
+	// case-sensible operations
+	FS FS
+
+	// returns a fresh, empty directory, and a function that removes it,
+	// both failing `t` if they can't
+	TempDir func(t testing.TB) (string, func())
+}
+
+// FS is the set of case-sensible operations the suite runs. *screw.FS
+// implements it, and so can wrappers of one.
+type FS interface {
+	screw.WritableFS
+	Open(name string) (screw.File, error)
+	Create(name string) (screw.File, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadDir(name string) ([]os.FileInfo, error)
+	IsWrongCase(name string) bool
+	TrueBaseName(name string) string
+}
+
+var _ FS = (*screw.FS)(nil)
+
+var tempDirs atomic.Int64
+
+// NewEnv returns an Env for `backend`, with an FS built on it with `opts`.
+// Temporary directories are made under os.TempDir() through the backend,
+// and the kind is probed in one of them, failing `t` if that's not possible.
+func NewEnv(t testing.TB, name string, backend screw.Backend, opts ...screw.Option) Env {
+	t.Helper()
+
+	fsys := screw.New(append([]screw.Option{screw.WithBackend(backend)}, opts...)...)
+	env := Env{
+		Name:    name,
+		Raw:     backend,
+		RawName: name,
+		FS:      fsys,
+		TempDir: func(t testing.TB) (string, func()) {
+			t.Helper()
+			dir := filepath.Join(os.TempDir(), fmt.Sprintf("screwtest-%d-%d", os.Getpid(), tempDirs.Add(1)))
+			require.NoError(t, backend.MkdirAll(dir, 0o755))
+			return dir, func() {
+				assert.NoError(t, backend.RemoveAll(dir), "removing %s", dir)
+			}
+		},
+	}
+
+	env.Kind = FSCaseSensitive
+	if backend.IsCaseInsensitive() {
+		env.Kind = FSCaseInsensitive
+	}
+	dir, cleanup := env.TempDir(t)
+	defer cleanup()
+	if insensitive, err := fsys.IsCaseInsensitiveDir(dir); err == nil {
+		env.Kind = FSCaseSensitive
+		if insensitive {
+			env.Kind = FSCaseInsensitive
+		}
+	}
+	return env
+}
+
+// Run runs the whole suite against `env`: the Cases table, then
+// TrueBaseName and case-only rename checks.
+func Run(t *testing.T, env Env) {
+	t.Run("Semantics", func(t *testing.T) {
+		runSemantics(t, env)
+	})
+	if env.Kind == FSCaseInsensitive {
+		t.Run("TrueBaseName", func(t *testing.T) {
+			runTrueBaseName(t, env)
+		})
+	}
+	t.Run("RenameCase", func(t *testing.T) {
+		runRenameCase(t, env)
+	})
+}
+
+func runSemantics(t *testing.T, env Env) {
+	testCases := Cases(env)
+
+	for _, tc := range testCases {
+		tc.AssertValid(t)
+		if !tc.ShouldRun(env) {
+			continue
+		}
+
+		fullName := tc.Name + "/" + tc.FSKind.String()
+
+		t.Run(fullName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			dir, cleanup := env.TempDir(t)
+			defer cleanup()
+
+			for _, name := range tc.DirsBefore {
+				fullName := filepath.Join(dir, name)
+				require.NoError(t, env.Raw.MkdirAll(fullName, 0o755))
+			}
+
+			for _, name := range tc.FilesBefore {
+				fullName := filepath.Join(dir, name)
+				require.NoError(t, env.Raw.MkdirAll(filepath.Dir(fullName), 0o755))
+
+				f, err := rawCreate(env.Raw)(fullName)
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			}
+
+			success, error := tc.Operation(filepath.Join(dir, tc.Argument))
+
+			if tc.Success {
+				assert.True(success, "operation should succeed")
+				assert.NoError(error, "operation should not have an error")
+			}
+
+			if tc.Error != nil {
+				assert.False(success, "operation should not succeed")
+				assert.NotNil(error)
+				if error != nil {
+					assert.True(tc.Error(error), "error must pass test function, was %+v", error)
+				}
+			}
+
+			for _, ea := range tc.FilesAfter {
+				stats, err := env.Raw.Stat(filepath.Join(dir, ea))
+				assert.NoError(err, "%s must exist after", ea)
+				if stats != nil {
+					assert.True(stats.Mode().IsRegular(), "%s must be a regular file after", ea)
+				}
+			}
+
+			for _, ea := range tc.DirsAfter {
+				stats, err := env.Raw.Stat(filepath.Join(dir, ea))
+				assert.NoError(err, "%s must exist after", ea)
+				if stats != nil {
+					assert.True(stats.IsDir(), "%s must be a directory after", ea)
+				}
+			}
+
+			for _, ea := range tc.AbsentAfter {
+				_, err := env.Raw.Stat(filepath.Join(dir, ea))
+				assert.True(err != nil, "%s must be absent after", ea)
+			}
+		})
+	}
+}
+
+func runTrueBaseName(t *testing.T, env Env) {
+	assert := assert.New(t)
+
+	tmpDir, cleanup := env.TempDir(t)
+	defer cleanup()
+
+	join := func(parts ...string) string {
+		parts = append([]string{tmpDir}, parts...)
+		return filepath.Join(parts...)
+	}
+	reference := join("foo", "bar", "baz")
+
+	err := env.Raw.MkdirAll(reference, 0o755)
+	require.NoError(t, err)
+
+	var is bool
+
+	is = env.FS.IsWrongCase(reference)
+	assert.False(is)
+
+	is = env.FS.IsWrongCase(join("foo", "bar", "BAZ"))
+	assert.True(is)
+
+	is = env.FS.IsWrongCase(join("foo", "BAR", "baz"))
+	assert.False(is)
+
+	is = env.FS.IsWrongCase(join("foo", "bar", "woops"))
+	assert.False(is)
+
+	var actual string
+
+	actual = env.FS.TrueBaseName(reference)
+	assert.EqualValues("baz", actual)
+
+	actual = env.FS.TrueBaseName(strings.ToUpper(reference))
+	assert.EqualValues("baz", actual)
+
+	actual = env.FS.TrueBaseName(strings.ToLower(reference))
+	assert.EqualValues("baz", actual)
+
+	actual = env.FS.TrueBaseName(join("FOO", "bar", "baz"))
+	assert.EqualValues("baz", actual)
+
+	actual = env.FS.TrueBaseName(join("foo", "BAR", "baz"))
+	assert.EqualValues("baz", actual)
+
+	// symlinks need privileges on Windows, and TrueBaseName can't be
+	// used on them on Linux (but that's case-sensitive anyway).
+	if _, isOS := env.Raw.(screw.OSBackend); !isOS || runtime.GOOS == "darwin" {
+		require.NoError(t, env.FS.WriteFile(join("file"), []byte("Some file"), 0644))
+		require.NoError(t, env.FS.Symlink(join("file"), join("link")))
+		assert.EqualValues("file", env.FS.TrueBaseName(join("file")))
+		assert.EqualValues("link", env.FS.TrueBaseName(join("link")))
+
+		dest, err := env.FS.Readlink(join("link"))
+		require.NoError(t, err)
+		assert.EqualValues("file", filepath.Base(dest))
+	}
+}
+
+func runRenameCase(t *testing.T, env Env) {
+	t.Run("file", func(t *testing.T) {
+		assert := assert.New(t)
+
+		tmpDir, cleanup := env.TempDir(t)
+		defer cleanup()
+
+		f, err := rawCreate(env.Raw)(filepath.Join(tmpDir, "foobar"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.EqualValues("foobar", env.FS.TrueBaseName(filepath.Join(tmpDir, "foobar")))
+		require.NoError(t, env.FS.Rename(filepath.Join(tmpDir, "foobar"), filepath.Join(tmpDir, "Foobar")))
+		assert.EqualValues("Foobar", env.FS.TrueBaseName(filepath.Join(tmpDir, "Foobar")))
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		assert := assert.New(t)
+
+		tmpDir, cleanup := env.TempDir(t)
+		defer cleanup()
+
+		err := env.Raw.MkdirAll(filepath.Join(tmpDir, "deeper", "and", "deeper"), 0o755)
+		require.NoError(t, err)
+
+		assert.EqualValues("deeper", env.FS.TrueBaseName(filepath.Join(tmpDir, "deeper")))
+		require.NoError(t, env.FS.Rename(filepath.Join(tmpDir, "deeper"), filepath.Join(tmpDir, "DEEPER")))
+		assert.EqualValues("DEEPER", env.FS.TrueBaseName(filepath.Join(tmpDir, "DEEPER")))
+	})
+}
@@ -1,39 +1,185 @@
 package screw
 
 import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"math"
 	"math/rand"
+	"os"
+	"syscall"
 	"time"
 )
 
+// RetryPolicy controls how operations that may fail spuriously, because
+// an antivirus has a file locked for example, are retried.
+//
+// Delays are either listed explicitly in Intervals, or computed by
+// exponential backoff from Initial, Multiplier and MaxInterval.
+type RetryPolicy struct {
+	// Intervals are the delays between successive attempts. When set,
+	// the exponential backoff parameters are ignored.
+	Intervals []time.Duration
+
+	// Initial is the first delay of exponential backoff. Zero (with no
+	// Intervals) disables retrying.
+	Initial time.Duration
+	// Multiplier grows the delay after each attempt. Values below 1
+	// are treated as 2.
+	Multiplier float64
+	// MaxInterval caps a single delay. Zero means no cap.
+	MaxInterval time.Duration
+	// MaxRetries caps the number of retries for exponential backoff.
+	// Zero means no cap, in which case only MaxElapsed stops retrying.
+	MaxRetries int
+
+	// MaxElapsed bounds the total time spent on an operation: no retry
+	// is attempted if its delay would go past it. Zero means no bound.
+	MaxElapsed time.Duration
+
+	// Jitter is the maximum random delay added to each interval.
+	Jitter time.Duration
+
+	// Retryable decides whether an error is worth retrying. When nil,
+	// the platform default is used: on Windows, errors caused by locked
+	// files (sharing and lock violations, and "access denied" on entries
+	// that aren't read-only) are retried; elsewhere, nothing is. Errors
+	// that won't go away by themselves, like ENOTEMPTY, EEXIST, EINVAL
+	// or ENAMETOOLONG, are never retried by default.
+	Retryable func(err error) bool
+
+	// Clock is used to measure time and wait between attempts. When nil,
+	// the real clock is used.
+	Clock Clock
+}
+
+// Clock abstracts time, so that retry behavior can be tested without
+// actually sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Intervals: []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			1600 * time.Millisecond,
+			3200 * time.Millisecond,
+		},
+		Jitter: 50 * time.Millisecond,
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return shouldRetry(err)
+}
+
+// isPermanent returns true for errors that retrying can't fix. On
+// Windows, ERROR_DIR_NOT_EMPTY and ERROR_ALREADY_EXISTS are fs.ErrExist.
+func isPermanent(err error) bool {
+	return errors.Is(err, syscall.ENOTEMPTY) ||
+		errors.Is(err, fs.ErrExist) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENAMETOOLONG) ||
+		os.IsNotExist(err)
+}
+
+func (p RetryPolicy) clock() Clock {
+	if p.Clock != nil {
+		return p.Clock
+	}
+	return realClock{}
+}
+
+// interval returns the delay before retry number `retry` (starting
+// at 0), and false if the policy has no such retry.
+func (p RetryPolicy) interval(retry int) (time.Duration, bool) {
+	if len(p.Intervals) > 0 {
+		if retry >= len(p.Intervals) {
+			return 0, false
+		}
+		return p.Intervals[retry], true
+	}
+
+	if p.Initial <= 0 || (p.MaxRetries > 0 && retry >= p.MaxRetries) {
+		return 0, false
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	interval := float64(p.Initial) * math.Pow(multiplier, float64(retry))
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		return p.MaxInterval, true
+	}
+	if interval > math.MaxInt64 {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(interval), true
+}
+
 type sleeper struct {
-	intervals []int
+	fs      *FS
+	policy  RetryPolicy
+	clock   Clock
+	start   time.Time
+	retries int
 }
 
-func newSleeper() *sleeper {
+func (fs *FS) newSleeper() *sleeper {
+	clock := fs.retry.clock()
 	return &sleeper{
-		intervals: []int{
-			100,
-			200,
-			400,
-			800,
-			1600,
-			3200,
-		},
+		fs:     fs,
+		policy: fs.retry,
+		clock:  clock,
+		start:  clock.Now(),
 	}
 }
 
-func (s *sleeper) Sleep(err error) bool {
-	if len(s.intervals) == 0 {
-		debugf("done sleeping, despite error %+v", err)
+// Sleep waits before the next attempt, and returns false if the
+// policy says to give up instead, or if ctx is done while waiting.
+func (s *sleeper) Sleep(ctx context.Context, err error) bool {
+	interval, ok := s.policy.interval(s.retries)
+	if !ok {
+		s.fs.debug("screw: out of retries", slog.Any("error", err))
 		return false
 	}
 
-	interval := s.intervals[0]
-	s.intervals = s.intervals[1:]
+	sleepTime := interval
+	if s.policy.Jitter > 0 {
+		sleepTime += time.Duration(rand.Int63n(int64(s.policy.Jitter)))
+	}
 
-	sleepTime := time.Duration(interval+rand.Intn(50)) * time.Millisecond
-	debugf("sleeping %v because of error %+v", sleepTime, err)
-	time.Sleep(sleepTime)
+	if s.policy.MaxElapsed > 0 && s.clock.Now().Add(sleepTime).Sub(s.start) > s.policy.MaxElapsed {
+		s.fs.debug("screw: out of time", slog.Any("error", err))
+		return false
+	}
 
-	return true
+	s.retries++
+	s.fs.debug("screw: retrying", slog.Duration("delay", sleepTime), slog.Any("error", err))
+	select {
+	case <-s.clock.After(sleepTime):
+		return true
+	case <-ctx.Done():
+		s.fs.debug("screw: interrupted while waiting to retry", slog.Any("error", ctx.Err()))
+		return false
+	}
 }
@@ -0,0 +1,402 @@
+package screw_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+var errLocked = errors.New("file is locked")
+
+// fakeClock doesn't sleep, it records the delays it's asked to wait
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) After(d time.Duration) <-chan time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.sleeps = append(fc.sleeps, d)
+	fc.now = fc.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- fc.now
+	return ch
+}
+
+// stuckClock never fires, it signals when someone starts waiting on it
+type stuckClock struct {
+	waiting chan struct{}
+}
+
+func (sc *stuckClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (sc *stuckClock) After(d time.Duration) <-chan time.Time {
+	sc.waiting <- struct{}{}
+	return make(chan time.Time)
+}
+
+// flakyBackend fails the first `failures` renames with errLocked
+type flakyBackend struct {
+	*screw.MemBackend
+	failures int
+	renames  int
+}
+
+func (fb *flakyBackend) Rename(oldpath, newpath string) error {
+	fb.renames++
+	if fb.renames <= fb.failures {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errLocked}
+	}
+	return fb.MemBackend.Rename(oldpath, newpath)
+}
+
+func isLocked(err error) bool {
+	return errors.Is(err, errLocked)
+}
+
+func newFlakyFS(failures int, policy screw.RetryPolicy) (*screw.FS, *flakyBackend) {
+	fb := &flakyBackend{MemBackend: screw.NewMemBackend(), failures: failures}
+	must(fb.MkdirAll(memPath(), 0o755))
+	writeMem(fb.MemBackend, memPath("apricot"), "apricot")
+	return screw.New(screw.WithBackend(fb), screw.WithRetryPolicy(policy)), fb
+}
+
+func Test_Retry_Intervals(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &fakeClock{}
+	policy := screw.DefaultRetryPolicy()
+	policy.Jitter = 0
+	policy.Retryable = isLocked
+	policy.Clock = clock
+
+	fsys, fb := newFlakyFS(3, policy)
+	assert.NoError(fsys.Rename(memPath("apricot"), memPath("banana")))
+	assert.EqualValues(4, fb.renames)
+	assert.EqualValues([]time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+	}, clock.sleeps)
+
+	// gives up once intervals run out
+	clock = &fakeClock{}
+	policy.Clock = clock
+	fsys, fb = newFlakyFS(100, policy)
+	err := fsys.Rename(memPath("apricot"), memPath("banana"))
+	assert.True(isLocked(err))
+	assert.EqualValues(7, fb.renames)
+	assert.Len(clock.sleeps, 6)
+}
+
+func Test_Retry_Exponential(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &fakeClock{}
+	policy := screw.RetryPolicy{
+		Initial:     10 * time.Millisecond,
+		Multiplier:  3,
+		MaxInterval: 200 * time.Millisecond,
+		MaxRetries:  5,
+		Retryable:   isLocked,
+		Clock:       clock,
+	}
+
+	fsys, fb := newFlakyFS(100, policy)
+	err := fsys.Rename(memPath("apricot"), memPath("banana"))
+	assert.True(isLocked(err))
+	assert.EqualValues(6, fb.renames)
+	assert.EqualValues([]time.Duration{
+		10 * time.Millisecond,
+		30 * time.Millisecond,
+		90 * time.Millisecond,
+		200 * time.Millisecond,
+		200 * time.Millisecond,
+	}, clock.sleeps)
+}
+
+func Test_Retry_MaxElapsed(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &fakeClock{}
+	policy := screw.RetryPolicy{
+		Initial:    100 * time.Millisecond,
+		MaxElapsed: time.Second,
+		Retryable:  isLocked,
+		Clock:      clock,
+	}
+
+	fsys, _ := newFlakyFS(100, policy)
+	err := fsys.Rename(memPath("apricot"), memPath("banana"))
+	assert.True(isLocked(err))
+	// 100 + 200 + 400 fits, another 800 wouldn't
+	assert.EqualValues([]time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+	}, clock.sleeps)
+}
+
+func Test_Retry_Classifier(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &fakeClock{}
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = func(err error) bool { return false }
+	policy.Clock = clock
+
+	fsys, fb := newFlakyFS(1, policy)
+	err := fsys.Rename(memPath("apricot"), memPath("banana"))
+	assert.True(isLocked(err))
+	assert.EqualValues(1, fb.renames)
+	assert.Empty(clock.sleeps)
+}
+
+func Test_Retry_PerCall(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &fakeClock{}
+	fsys, fb := newFlakyFS(2, screw.RetryPolicy{})
+
+	err := fsys.Rename(memPath("apricot"), memPath("banana"))
+	assert.True(isLocked(err))
+	assert.EqualValues(1, fb.renames)
+
+	patient := fsys.With(screw.WithRetryPolicy(screw.RetryPolicy{
+		Intervals: []time.Duration{time.Second},
+		Retryable: isLocked,
+		Clock:     clock,
+	}))
+	assert.NoError(patient.Rename(memPath("apricot"), memPath("banana")))
+	assert.EqualValues(3, fb.renames)
+	assert.EqualValues([]time.Duration{time.Second}, clock.sleeps)
+}
+
+func Test_Retry_Context(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &stuckClock{waiting: make(chan struct{}, 1)}
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = isLocked
+	policy.Clock = clock
+
+	fsys, fb := newFlakyFS(100, policy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-clock.waiting
+		cancel()
+	}()
+
+	err := fsys.RenameContext(ctx, memPath("apricot"), memPath("banana"))
+	assert.True(errors.Is(err, context.Canceled))
+	assert.True(isLocked(err), "must carry the last filesystem error")
+	assert.EqualValues(1, fb.renames)
+
+	// already-cancelled contexts prevent any attempt
+	fb.renames = 0
+	err = fsys.RenameContext(ctx, memPath("apricot"), memPath("banana"))
+	assert.True(errors.Is(err, context.Canceled))
+	assert.EqualValues(0, fb.renames)
+
+	err = fsys.RemoveContext(ctx, memPath("apricot"))
+	assert.True(errors.Is(err, context.Canceled))
+	err = fsys.RemoveAllContext(ctx, memPath())
+	assert.True(errors.Is(err, context.Canceled))
+	assert.EqualValues("apricot", readMem(fb.MemBackend, memPath("apricot")))
+}
+
+var errBroken = errors.New("broken for good")
+
+// lockedBackend fails removing some paths a given number of times,
+// some others for good, and counts removals of every path
+type lockedBackend struct {
+	*screw.MemBackend
+	locks      map[string]int
+	broken     map[string]bool
+	removes    map[string]int
+	removeAlls int
+}
+
+func (lb *lockedBackend) Remove(name string) error {
+	lb.removes[name]++
+	if lb.broken[name] {
+		return &os.PathError{Op: "remove", Path: name, Err: errBroken}
+	}
+	if lb.locks[name] > 0 {
+		lb.locks[name]--
+		return &os.PathError{Op: "remove", Path: name, Err: errLocked}
+	}
+	return lb.MemBackend.Remove(name)
+}
+
+// RemoveAll fails without removing anything if an entry of the tree is
+// locked, forcing RemoveAll to fall back to removing entries one by one
+func (lb *lockedBackend) RemoveAll(name string) error {
+	lb.removeAlls++
+	for path, locks := range lb.locks {
+		if locks > 0 && (path == name || strings.HasPrefix(path, name+string(filepath.Separator))) {
+			return &os.PathError{Op: "unlinkat", Path: path, Err: errLocked}
+		}
+	}
+	return lb.MemBackend.RemoveAll(name)
+}
+
+func newLockedFS(locks map[string]int) (*screw.FS, *lockedBackend, *fakeClock) {
+	lb := &lockedBackend{
+		MemBackend: screw.NewMemBackend(),
+		locks:      locks,
+		removes:    make(map[string]int),
+	}
+	clock := &fakeClock{}
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = isLocked
+	policy.Clock = clock
+	return screw.New(screw.WithBackend(lb), screw.WithRetryPolicy(policy)), lb, clock
+}
+
+func Test_Retry_Remove(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys, lb, clock := newLockedFS(map[string]int{memPath("apricot"): 2})
+	must(lb.MkdirAll(memPath(), 0o755))
+	writeMem(lb.MemBackend, memPath("apricot"), "apricot")
+
+	assert.NoError(fsys.Remove(memPath("apricot")))
+	assert.EqualValues(3, lb.removes[memPath("apricot")])
+	assert.Len(clock.sleeps, 2)
+
+	_, err := lb.Stat(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_Retry_RemoveAll(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys, lb, clock := newLockedFS(map[string]int{memPath("tree", "b", "locked"): 3})
+	must(lb.MkdirAll(memPath("tree", "a"), 0o755))
+	must(lb.MkdirAll(memPath("tree", "b"), 0o755))
+	writeMem(lb.MemBackend, memPath("tree", "a", "file"), "file")
+	writeMem(lb.MemBackend, memPath("tree", "b", "locked"), "locked")
+	writeMem(lb.MemBackend, memPath("tree", "c"), "c")
+
+	assert.NoError(fsys.RemoveAll(memPath("tree")))
+	assert.Len(clock.sleeps, 3)
+
+	// only the locked entry is retried, the walk doesn't start over
+	assert.EqualValues(4, lb.removes[memPath("tree", "b", "locked")])
+	for _, name := range []string{
+		memPath("tree"),
+		memPath("tree", "a"),
+		memPath("tree", "a", "file"),
+		memPath("tree", "b"),
+		memPath("tree", "c"),
+	} {
+		assert.EqualValues(1, lb.removes[name], name)
+	}
+
+	_, err := lb.Stat(memPath("tree"))
+	assert.True(os.IsNotExist(err))
+
+	// gives up on entries that stay locked, but removes the rest
+	fsys, lb, _ = newLockedFS(map[string]int{memPath("tree", "b", "locked"): 100})
+	must(lb.MkdirAll(memPath("tree", "a"), 0o755))
+	must(lb.MkdirAll(memPath("tree", "b"), 0o755))
+	writeMem(lb.MemBackend, memPath("tree", "a", "file"), "file")
+	writeMem(lb.MemBackend, memPath("tree", "b", "locked"), "locked")
+
+	err = fsys.RemoveAll(memPath("tree"))
+	assert.True(isLocked(err))
+	_, err = lb.Stat(memPath("tree", "a"))
+	assert.True(os.IsNotExist(err))
+	assert.EqualValues("locked", readMem(lb.MemBackend, memPath("tree", "b", "locked")))
+
+	// without locks, the backend's RemoveAll does all the work
+	fsys, lb, clock = newLockedFS(map[string]int{})
+	must(lb.MkdirAll(memPath("tree", "a"), 0o755))
+	writeMem(lb.MemBackend, memPath("tree", "a", "file"), "file")
+
+	assert.NoError(fsys.RemoveAll(memPath("tree")))
+	assert.EqualValues(1, lb.removeAlls)
+	assert.Empty(lb.removes)
+	assert.Empty(clock.sleeps)
+	_, err = lb.Stat(memPath("tree"))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_Retry_DefaultPermanent(t *testing.T) {
+	assert := assert.New(t)
+
+	// the platform default classifier, with a clock that doesn't sleep
+	clock := &fakeClock{}
+	policy := screw.DefaultRetryPolicy()
+	policy.Clock = clock
+
+	tmpDir := t.TempDir()
+	must(os.MkdirAll(filepath.Join(tmpDir, "full"), 0o755))
+	must(os.WriteFile(filepath.Join(tmpDir, "full", "apricot"), nil, 0o644))
+	must(os.WriteFile(filepath.Join(tmpDir, "banana"), nil, 0o644))
+	fsys := screw.New(screw.WithRetryPolicy(policy))
+
+	// a directory that isn't empty won't become empty by waiting
+	err := fsys.Remove(filepath.Join(tmpDir, "full"))
+	assert.Error(err)
+	assert.Empty(clock.sleeps)
+
+	// neither will a file appear where a directory should be
+	err = fsys.Remove(filepath.Join(tmpDir, "banana", "nope"))
+	assert.Error(err)
+	assert.Empty(clock.sleeps)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("full"), 0o755))
+	writeMem(mem, memPath("full", "apricot"), "apricot")
+	err = screw.New(screw.WithBackend(mem), screw.WithRetryPolicy(policy)).Remove(memPath("full"))
+	assert.Error(err)
+	assert.Empty(clock.sleeps)
+}
+
+func Test_Retry_RemoveAllContext(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys, lb, _ := newLockedFS(map[string]int{memPath("tree", "b"): 100})
+	lb.broken = map[string]bool{memPath("tree", "a"): true}
+	must(lb.MkdirAll(memPath("tree"), 0o755))
+	writeMem(lb.MemBackend, memPath("tree", "a"), "a")
+	writeMem(lb.MemBackend, memPath("tree", "b"), "b")
+
+	clock := &stuckClock{waiting: make(chan struct{})}
+	policy := screw.DefaultRetryPolicy()
+	policy.Retryable = isLocked
+	policy.Clock = clock
+	fsys = fsys.With(screw.WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-clock.waiting
+		cancel()
+	}()
+
+	// "a" fails first, then the context is done while retrying "b"
+	err := fsys.RemoveAllContext(ctx, memPath("tree"))
+	assert.True(errors.Is(err, context.Canceled))
+	assert.True(errors.Is(err, errBroken), "must carry the first error")
+}
@@ -0,0 +1,149 @@
+package screw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WithRoot sets the directory below which CaseStrict checks every
+// path component. Components of the root itself aren't checked.
+// Without a root, strict mode checks paths all the way up to the
+// volume root.
+func WithRoot(root string) Option {
+	return func(fs *FS) {
+		fs.root = root
+	}
+}
+
+// wrongCaseComponent returns the absolute path of the first component
+// of `name` (below the root) that exists on disk with a different case,
+// along with its on-disk path, or "" if there is none.
+func (fs *FS) wrongCaseComponent(name string) (wrong string, actual string) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", ""
+	}
+
+	root := fs.strictRoot(abs)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// outside of the root, only check the base name
+		if trueBase := fs.trueBaseIfWrong(abs); trueBase != "" {
+			return abs, filepath.Join(filepath.Dir(abs), trueBase)
+		}
+		return "", ""
+	}
+	if rel == "." {
+		return "", ""
+	}
+
+	current := root
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		last := i == len(parts)-1
+
+		// the last component is never cached: it's often a file,
+		// and files come and go far more often than directories.
+		if !last && fs.verified.has(current) {
+			continue
+		}
+
+		trueBase := fs.backend.TrueBaseName(current)
+		if trueBase == "" {
+			// doesn't exist, so neither do any of its children
+			return "", ""
+		}
+		if !fs.sameName(trueBase, part) {
+			return current, filepath.Join(filepath.Dir(current), trueBase)
+		}
+		if !last {
+			fs.verified.add(current)
+		}
+	}
+	return "", ""
+}
+
+func (fs *FS) strictRoot(abs string) string {
+	if fs.root != "" {
+		root, err := filepath.Abs(fs.root)
+		if err == nil {
+			return root
+		}
+	}
+	return filepath.VolumeName(abs) + string(filepath.Separator)
+}
+
+// ForgetVerifiedCase empties the cache of directories CaseStrict has
+// found to have the right case, and the directory listings CaseLenient
+// uses. Changes made through the FS keep the caches up-to-date, this is
+// only needed when other processes (or other FS instances) rename or
+// remove directories below the root.
+func (fs *FS) ForgetVerifiedCase() {
+	fs.verified.clear()
+	fs.listings.clear()
+}
+
+// forgetCase drops what the caches know about `name`
+// and everything below it.
+func (fs *FS) forgetCase(name string) {
+	fs.verified.forget(name)
+	fs.listings.forget(name)
+}
+
+// caseCache is a set of absolute paths, in their on-disk casing
+type caseCache struct {
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+func newCaseCache() *caseCache {
+	return &caseCache{
+		paths: make(map[string]struct{}),
+	}
+}
+
+func (cc *caseCache) has(path string) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	_, ok := cc.paths[path]
+	return ok
+}
+
+func (cc *caseCache) add(path string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.paths[path] = struct{}{}
+}
+
+// forget removes `name`, any case variant of it, and everything
+// below them from the cache.
+func (cc *caseCache) forget(name string) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		cc.clear()
+		return
+	}
+	prefix := foldName(abs)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for path := range cc.paths {
+		folded := foldName(path)
+		if folded == prefix || strings.HasPrefix(folded, prefix+string(os.PathSeparator)) {
+			delete(cc.paths, path)
+		}
+	}
+}
+
+func (cc *caseCache) clear() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	clear(cc.paths)
+}
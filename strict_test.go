@@ -0,0 +1,95 @@
+package screw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingBackend counts TrueBaseName lookups
+type countingBackend struct {
+	*screw.MemBackend
+	lookups int
+}
+
+func (cb *countingBackend) TrueBaseName(name string) string {
+	cb.lookups++
+	return cb.MemBackend.TrueBaseName(name)
+}
+
+func Test_Strict_ParentComponents(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("root", "FOO", "bar"), 0o755))
+	writeMem(mem, memPath("root", "FOO", "bar", "baz"), "baz")
+
+	sensible := screw.New(screw.WithBackend(mem))
+	strict := screw.New(screw.WithBackend(mem), screw.WithCaseMode(screw.CaseStrict), screw.WithRoot(memPath("root")))
+
+	// sensible mode only checks the base name
+	_, err := sensible.Stat(memPath("root", "foo", "bar", "baz"))
+	assert.NoError(err)
+
+	_, err = strict.Stat(memPath("root", "foo", "bar", "baz"))
+	assert.True(os.IsNotExist(err))
+	var pe *os.PathError
+	if assert.True(errors.As(err, &pe)) {
+		assert.EqualValues(memPath("root", "foo"), pe.Path, "must name the first offending component")
+	}
+
+	_, err = strict.Stat(memPath("root", "FOO", "bar", "baz"))
+	assert.NoError(err)
+
+	err = strict.WriteFile(memPath("root", "foo", "bar", "new"), []byte("new"), 0o644)
+	assert.True(errors.Is(err, screw.ErrCaseConflict))
+
+	err = strict.MkdirAll(memPath("root", "foo", "bar", "deeper"), 0o755)
+	assert.True(errors.Is(err, screw.ErrCaseConflict))
+
+	// components of the root itself aren't checked
+	_, err = strict.Stat(memPath("ROOT", "FOO", "bar", "baz"))
+	assert.NoError(err)
+
+	// nonexistent paths are reported by the backend as usual
+	_, err = strict.Stat(memPath("root", "FOO", "nope", "baz"))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_Strict_Cache(t *testing.T) {
+	assert := assert.New(t)
+
+	cb := &countingBackend{MemBackend: screw.NewMemBackend()}
+	must(cb.MkdirAll(memPath("root", "a", "b", "c"), 0o755))
+	writeMem(cb.MemBackend, memPath("root", "a", "b", "c", "file"), "file")
+
+	strict := screw.New(screw.WithBackend(cb), screw.WithCaseMode(screw.CaseStrict), screw.WithRoot(memPath("root")))
+
+	_, err := strict.Stat(memPath("root", "a", "b", "c", "file"))
+	must(err)
+	assert.EqualValues(4, cb.lookups)
+
+	// parent directories are cached, only the base name is looked up again
+	cb.lookups = 0
+	_, err = strict.Stat(memPath("root", "a", "b", "c", "file"))
+	must(err)
+	assert.EqualValues(1, cb.lookups)
+
+	// renames through the FS invalidate the cache
+	must(strict.Rename(memPath("root", "a", "b"), memPath("root", "a", "B")))
+	_, err = strict.Stat(memPath("root", "a", "b", "c", "file"))
+	assert.True(os.IsNotExist(err))
+	_, err = strict.Stat(memPath("root", "a", "B", "c", "file"))
+	assert.NoError(err)
+
+	// changes made behind the FS's back need an explicit reset
+	must(cb.Rename(memPath("root", "a", "B"), memPath("root", "a", "b")))
+	_, err = strict.Stat(memPath("root", "a", "B", "c", "file"))
+	assert.NoError(err, "stale cache entry")
+	strict.ForgetVerifiedCase()
+	_, err = strict.Stat(memPath("root", "a", "B", "c", "file"))
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,73 @@
+package screw
+
+import "sync"
+
+// CaseViolation describes a path that was used with a case
+// different from the one on disk.
+type CaseViolation struct {
+	// Op is the operation that detected the violation, like "screw.Stat"
+	Op string
+	// Path is the path as requested. In strict mode, it stops at the
+	// first component that has the wrong case.
+	Path string
+	// Actual is Path, with the on-disk name as its last component
+	Actual string
+}
+
+// CaseViolationFunc is called for every case violation an FS detects,
+// see OnCaseViolation. It's called synchronously, from the goroutine
+// doing the operation.
+type CaseViolationFunc func(v CaseViolation)
+
+// WithCaseViolationHandler calls fn for every case violation the FS
+// detects, see OnCaseViolation
+func WithCaseViolationHandler(fn CaseViolationFunc) Option {
+	return func(fs *FS) {
+		fs.violations = fs.violations.with(fn)
+	}
+}
+
+// OnCaseViolation registers fn to be called whenever an operation
+// finds that a path was given with the wrong case, whether that ends
+// up as an error (os.ErrNotExist, ErrCaseConflict) or not (RemoveAll
+// does nothing). IsWrongCase doesn't count, since it's only a query.
+//
+// Copies of the FS made by With share handlers registered this way.
+func (fs *FS) OnCaseViolation(fn CaseViolationFunc) {
+	fs.violations.add(fn)
+}
+
+// OnCaseViolation registers a case violation handler on the default FS,
+// see (*FS).OnCaseViolation
+func OnCaseViolation(fn CaseViolationFunc) {
+	Default().OnCaseViolation(fn)
+}
+
+type violationHandlers struct {
+	mu  sync.Mutex
+	fns []CaseViolationFunc
+}
+
+func (vh *violationHandlers) add(fn CaseViolationFunc) {
+	vh.mu.Lock()
+	defer vh.mu.Unlock()
+	vh.fns = append(vh.fns, fn)
+}
+
+// with returns a copy of vh with fn added, leaving vh untouched
+func (vh *violationHandlers) with(fn CaseViolationFunc) *violationHandlers {
+	vh.mu.Lock()
+	defer vh.mu.Unlock()
+	fns := append([]CaseViolationFunc(nil), vh.fns...)
+	return &violationHandlers{fns: append(fns, fn)}
+}
+
+func (vh *violationHandlers) report(v CaseViolation) {
+	vh.mu.Lock()
+	fns := vh.fns
+	vh.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(v)
+	}
+}
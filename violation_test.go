@@ -0,0 +1,90 @@
+package screw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CaseViolation(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("DIR"), 0o755))
+	writeMem(mem, memPath("APRICOT"), "apricot")
+
+	var violations []screw.CaseViolation
+	fsys := screw.New(screw.WithBackend(mem), screw.WithCaseViolationHandler(func(v screw.CaseViolation) {
+		violations = append(violations, v)
+	}))
+
+	_, err := fsys.Stat(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+	_, err = fsys.Open(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+	_, err = fsys.ReadFile(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+	err = fsys.Remove(memPath("apricot"))
+	assert.True(os.IsNotExist(err))
+	assert.NoError(fsys.RemoveAll(memPath("dir")))
+	err = fsys.WriteFile(memPath("apricot"), []byte("apricot"), 0o644)
+	assert.True(screw.IsCaseConflict(err))
+
+	// neither queries nor correct paths are violations
+	assert.True(fsys.IsWrongCase(memPath("apricot")))
+	_, err = fsys.Stat(memPath("APRICOT"))
+	assert.NoError(err)
+
+	assert.EqualValues([]screw.CaseViolation{
+		{Op: "screw.Stat", Path: memPath("apricot"), Actual: memPath("APRICOT")},
+		{Op: "screw.Open", Path: memPath("apricot"), Actual: memPath("APRICOT")},
+		{Op: "screw.ReadFile", Path: memPath("apricot"), Actual: memPath("APRICOT")},
+		{Op: "screw.Remove", Path: memPath("apricot"), Actual: memPath("APRICOT")},
+		{Op: "screw.RemoveAll", Path: memPath("dir"), Actual: memPath("DIR")},
+		{Op: "screw.WriteFile", Path: memPath("apricot"), Actual: memPath("APRICOT")},
+	}, violations)
+}
+
+func Test_CaseViolation_Register(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := screw.NewMemBackend()
+	must(mem.MkdirAll(memPath("root", "FOO"), 0o755))
+	writeMem(mem, memPath("root", "FOO", "bar"), "bar")
+
+	parent := screw.New(screw.WithBackend(mem))
+	strict := parent.With(screw.WithCaseMode(screw.CaseStrict), screw.WithRoot(memPath("root")))
+
+	var parentCount int
+	parent.OnCaseViolation(func(v screw.CaseViolation) {
+		parentCount++
+	})
+
+	var got []screw.CaseViolation
+	strict.OnCaseViolation(func(v screw.CaseViolation) {
+		got = append(got, v)
+	})
+
+	_, err := strict.Stat(memPath("root", "foo", "bar"))
+	assert.True(os.IsNotExist(err))
+	assert.EqualValues([]screw.CaseViolation{
+		{Op: "screw.Stat", Path: memPath("root", "foo"), Actual: memPath("root", "FOO")},
+	}, got)
+
+	// handlers registered after With are shared
+	assert.EqualValues(1, parentCount)
+
+	// handlers given as options aren't
+	var optionCount int
+	child := parent.With(screw.WithCaseViolationHandler(func(v screw.CaseViolation) {
+		optionCount++
+	}))
+	_, err = parent.Stat(memPath("root", "foo"))
+	assert.True(os.IsNotExist(err))
+	assert.EqualValues(0, optionCount)
+	_, err = child.Stat(memPath("root", "foo"))
+	assert.True(os.IsNotExist(err))
+	assert.EqualValues(1, optionCount)
+}
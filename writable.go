@@ -0,0 +1,32 @@
+package screw
+
+import (
+	"os"
+	"time"
+)
+
+// WritableFS is the set of operations code that modifies a tree needs,
+// with screw's case-sensible contract: operating on an existing file
+// with the wrong case fails, either with os.ErrNotExist or with
+// ErrCaseConflict, as documented for each operation.
+//
+// *FS implements it, so code written against WritableFS can run on the
+// real filesystem (Default, which the package-level functions use), on
+// a MemBackend, or on anything else that wraps one, like a dry-run
+// recorder.
+type WritableFS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldpath, newpath string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Truncate(name string, size int64) error
+	Lstat(name string) (os.FileInfo, error)
+	Chmod(name string, mode os.FileMode) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+var _ WritableFS = (*FS)(nil)
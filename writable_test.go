@@ -0,0 +1,71 @@
+package screw_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itchio/screw"
+	"github.com/stretchr/testify/assert"
+)
+
+// applyPatch only knows about WritableFS, like patchers do
+func applyPatch(w screw.WritableFS, dir string) error {
+	if err := w.MkdirAll(filepath.Join(dir, "Data"), 0o755); err != nil {
+		return err
+	}
+	f, err := w.OpenFile(filepath.Join(dir, "Data", "Apricot"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte("apricot")); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := w.Chmod(filepath.Join(dir, "Data", "Apricot"), 0o600); err != nil {
+		return err
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := w.Chtimes(filepath.Join(dir, "Data", "Apricot"), mtime, mtime); err != nil {
+		return err
+	}
+	return w.Rename(filepath.Join(dir, "Data", "Apricot"), filepath.Join(dir, "Data", "Banana"))
+}
+
+func Test_WritableFS(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	check := func(t *testing.T, w screw.WritableFS, dir string) {
+		assert := assert.New(t)
+		assert.NoError(applyPatch(w, dir))
+
+		stats, err := w.Lstat(filepath.Join(dir, "Data", "Banana"))
+		if assert.NoError(err) {
+			assert.EqualValues(7, stats.Size())
+			assert.True(mtime.Equal(stats.ModTime()))
+			if runtime.GOOS != "windows" {
+				assert.EqualValues(0o600, stats.Mode().Perm())
+			}
+		}
+
+		// wrong case is a miss, as with Stat
+		err = w.Chmod(filepath.Join(dir, "Data", "BANANA"), 0o644)
+		assert.True(os.IsNotExist(err))
+		err = w.Chtimes(filepath.Join(dir, "Data", "BANANA"), mtime, mtime)
+		assert.True(os.IsNotExist(err))
+	}
+
+	t.Run("mem", func(t *testing.T) {
+		mem := screw.NewMemBackend()
+		check(t, screw.New(screw.WithBackend(mem)), memPath())
+	})
+
+	t.Run("os", func(t *testing.T) {
+		check(t, screw.Default(), t.TempDir())
+	})
+}